@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"encoding/json"
+	"bufio"
 	"fmt"
 	"os"
 
@@ -15,6 +15,14 @@ var (
 	backupAddr    string
 	backupToken   string
 	backupEngines []string
+	backupPass    string
+	backupRcpts   []string
+	backupWorkers int
+	backupMaxRPS  float64
+	backupRetries int
+	backupNS      string
+	backupRecurse bool
+	backupHashes  bool
 )
 
 var backupCmd = &cobra.Command{
@@ -29,6 +37,14 @@ func init() {
 	backupCmd.Flags().StringVarP(&backupAddr, "address", "a", "", "Vault server address (or set VAULT_ADDR)")
 	backupCmd.Flags().StringVarP(&backupToken, "token", "t", "", "Vault token (or set VAULT_TOKEN)")
 	backupCmd.Flags().StringSliceVarP(&backupEngines, "engines", "e", []string{}, "Specific secret engines to backup (empty = all)")
+	backupCmd.Flags().StringVarP(&backupNS, "namespace", "n", "", "Vault namespace to back up (or set VAULT_NAMESPACE)")
+	backupCmd.Flags().BoolVar(&backupRecurse, "recursive", false, "Also back up every child namespace")
+	backupCmd.Flags().StringVar(&backupPass, "passphrase", "", "Encrypt the backup with a passphrase (or set VAULT_MIGRATOR_PASSPHRASE)")
+	backupCmd.Flags().StringSliceVarP(&backupRcpts, "recipient", "r", []string{}, "Encrypt the backup to an age public key (repeatable)")
+	backupCmd.Flags().BoolVar(&backupHashes, "userpass-hashes", false, "Back up userpass password hashes through sys/raw (needs sudo on sys/raw, root namespace only)")
+	backupCmd.Flags().IntVar(&backupWorkers, "concurrency", vault.DefaultConcurrency, "Number of secrets to read in parallel")
+	backupCmd.Flags().Float64Var(&backupMaxRPS, "max-rps", 0, "Maximum requests per second to Vault (0 = unlimited)")
+	backupCmd.Flags().IntVar(&backupRetries, "max-retries", vault.DefaultRequestOptions().MaxRetries, "Retries per request on 429 and 5xx responses")
 }
 
 func runBackup(cmd *cobra.Command, args []string) error {
@@ -40,42 +56,95 @@ func runBackup(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("Connecting to Vault at %s...\n", addr)
-	client, err := vault.NewClient(addr, token)
+	client, err := vault.NewClientWithOptions(addr, token, requestOptions(backupMaxRPS, backupRetries))
 	if err != nil {
 		return fmt.Errorf("failed to create vault client: %w", err)
 	}
+	client.SetNamespace(getEnvOrFlag(backupNS, "VAULT_NAMESPACE"))
+
+	encryption := vault.EncryptionOptions{
+		Passphrase: getEnvOrFlag(backupPass, "VAULT_MIGRATOR_PASSPHRASE"),
+		Recipients: backupRcpts,
+	}
+	if backupHashes && !encryption.Enabled() {
+		fmt.Println("Warning: the backup will contain userpass password hashes; consider --passphrase or --recipient")
+	}
 
 	fmt.Println("Starting backup process...")
-	backup, err := client.Backup(backupEngines)
+	stats, err := writeBackupFile(backupFile, encryption, func(sink vault.BackupSink) error {
+		return client.Backup(vault.BackupOptions{
+			Engines:        backupEngines,
+			Recursive:      backupRecurse,
+			Concurrency:    backupWorkers,
+			UserpassHashes: backupHashes,
+		}, sink)
+	})
 	if err != nil {
 		return fmt.Errorf("backup failed: %w", err)
 	}
 
-	data, err := json.MarshalIndent(backup, "", "  ")
+	failed := client.Failures()
+	if failed > 0 {
+		fmt.Printf("\n✗ Backup incomplete: %d secrets failed after retries\n", failed)
+	} else {
+		fmt.Printf("\n✓ Backup completed successfully!\n")
+	}
+	fmt.Printf("  File: %s\n", backupFile)
+	if encryption.Enabled() {
+		fmt.Printf("  Encrypted: yes\n")
+	}
+	fmt.Printf("  Secret Engines: %d\n", stats.SecretEngines)
+	fmt.Printf("  Total Secrets: %d\n", stats.Secrets)
+	fmt.Printf("  Policies: %d\n", stats.Policies)
+	fmt.Printf("  Auth Methods: %d\n", stats.AuthMethods)
+	fmt.Printf("  Entities: %d\n", stats.Entities)
+	fmt.Printf("  Groups: %d\n", stats.Groups)
+	if stats.Namespaces > 0 {
+		fmt.Printf("  Namespaces: %d\n", stats.Namespaces)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d secrets could not be backed up", failed)
+	}
+	return nil
+}
+
+// writeBackupFile streams records produced by fill into an (optionally
+// encrypted) backup file.
+func writeBackupFile(path string, encryption vault.EncryptionOptions, fill func(vault.BackupSink) error) (vault.BackupStats, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
 	if err != nil {
-		return fmt.Errorf("failed to marshal backup data: %w", err)
+		return vault.BackupStats{}, fmt.Errorf("failed to write backup file: %w", err)
 	}
+	defer f.Close()
 
-	if err := os.WriteFile(backupFile, data, 0600); err != nil {
-		return fmt.Errorf("failed to write backup file: %w", err)
+	w, err := vault.NewEncryptingWriter(f, encryption)
+	if err != nil {
+		return vault.BackupStats{}, fmt.Errorf("failed to set up encryption: %w", err)
 	}
 
-	fmt.Printf("\n✓ Backup completed successfully!\n")
-	fmt.Printf("  File: %s\n", backupFile)
-	fmt.Printf("  Secret Engines: %d\n", len(backup.SecretEngines))
-	fmt.Printf("  Total Secrets: %d\n", countSecrets(backup))
-	fmt.Printf("  Policies: %d\n", len(backup.Policies))
-	fmt.Printf("  Auth Methods: %d\n", len(backup.AuthMethods))
+	buf := bufio.NewWriter(w)
+	stream := vault.NewStreamWriter(buf)
+	if err := fill(stream); err != nil {
+		return stream.Stats(), err
+	}
 
-	return nil
+	if err := buf.Flush(); err != nil {
+		return stream.Stats(), fmt.Errorf("failed to write backup file: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return stream.Stats(), fmt.Errorf("failed to finalize backup file: %w", err)
+	}
+
+	return stream.Stats(), f.Close()
 }
 
-func countSecrets(backup *vault.BackupData) int {
-	count := 0
-	for _, engine := range backup.SecretEngines {
-		count += len(engine.Secrets)
-	}
-	return count
+// requestOptions applies the rate limit and retry flags to the defaults
+func requestOptions(maxRPS float64, maxRetries int) vault.RequestOptions {
+	opts := vault.DefaultRequestOptions()
+	opts.MaxRPS = maxRPS
+	opts.MaxRetries = maxRetries
+	return opts
 }
 
 func getEnvOrFlag(flag, envVar string) string {
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"vault-migrator/pkg/vault"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffSourceToken string
+	diffTargetToken string
+	diffEngines     []string
+	diffShowValues  bool
+	diffJSONFile    string
+	diffPass        string
+	diffIdentities  []string
+	diffRecursive   bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <source> <target>",
+	Short: "Compare two backups or live Vault servers",
+	Long: `Compare secret engines, secrets, policies, and auth methods between two sources.
+Each source is either a backup file or a Vault address (http:// or https://).
+Secret values are masked unless --show-values is set.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffSourceToken, "source-token", "", "Token for a live source Vault (or set VAULT_SOURCE_TOKEN)")
+	diffCmd.Flags().StringVar(&diffTargetToken, "target-token", "", "Token for a live target Vault (or set VAULT_TARGET_TOKEN)")
+	diffCmd.Flags().StringSliceVarP(&diffEngines, "engines", "e", []string{}, "Specific secret engines to compare (empty = all)")
+	diffCmd.Flags().BoolVar(&diffShowValues, "show-values", false, "Show secret values in the report instead of masking them")
+	diffCmd.Flags().StringVar(&diffJSONFile, "json", "", "Also write the diff as JSON to this file")
+	diffCmd.Flags().BoolVar(&diffRecursive, "recursive", false, "Also read child namespaces of a live Vault")
+	diffCmd.Flags().StringVar(&diffPass, "passphrase", "", "Passphrase for encrypted backups (or set VAULT_MIGRATOR_PASSPHRASE)")
+	diffCmd.Flags().StringSliceVarP(&diffIdentities, "identity", "i", []string{}, "age identity file for encrypted backups (repeatable)")
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	decryption := vault.DecryptionOptions{
+		Passphrase:    getEnvOrFlag(diffPass, "VAULT_MIGRATOR_PASSPHRASE"),
+		IdentityFiles: diffIdentities,
+	}
+
+	left, err := loadBackupData(args[0], getEnvOrFlag(diffSourceToken, "VAULT_SOURCE_TOKEN"), decryption)
+	if err != nil {
+		return fmt.Errorf("failed to load source: %w", err)
+	}
+
+	right, err := loadBackupData(args[1], getEnvOrFlag(diffTargetToken, "VAULT_TARGET_TOKEN"), decryption)
+	if err != nil {
+		return fmt.Errorf("failed to load target: %w", err)
+	}
+
+	report := vault.Diff(left, right, vault.DiffOptions{
+		Engines:    diffEngines,
+		ShowValues: diffShowValues,
+	})
+
+	fmt.Printf("\nDiff %s → %s\n\n", args[0], args[1])
+	printDiff(report)
+
+	if diffJSONFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal diff: %w", err)
+		}
+		if err := os.WriteFile(diffJSONFile, data, 0600); err != nil {
+			return fmt.Errorf("failed to write diff file: %w", err)
+		}
+		fmt.Printf("\n  JSON diff written to %s\n", diffJSONFile)
+	}
+
+	return nil
+}
+
+// loadBackupData reads a backup file, or backs up a live Vault into memory
+// when location is an address.
+func loadBackupData(location, token string, decryption vault.DecryptionOptions) (*vault.BackupData, error) {
+	collector := vault.NewBackupCollector()
+
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		if token == "" {
+			return nil, fmt.Errorf("a token is required for %s", location)
+		}
+
+		fmt.Printf("Reading Vault at %s...\n", location)
+		client, err := vault.NewClient(location, token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vault client: %w", err)
+		}
+		if err := client.Backup(vault.BackupOptions{Engines: diffEngines, Recursive: diffRecursive, Concurrency: vault.DefaultConcurrency}, collector); err != nil {
+			return nil, err
+		}
+		return collector.Data, nil
+	}
+
+	source, closeFile, err := openBackupFile(location, decryption)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+
+	if err := source.Replay(collector); err != nil {
+		return nil, err
+	}
+	return collector.Data, nil
+}
+
+func printDiff(report *vault.DiffReport) {
+	if len(report.Entries) == 0 {
+		fmt.Println("  No differences")
+		return
+	}
+
+	symbols := map[vault.DiffChange]string{
+		vault.DiffAdded:   "+",
+		vault.DiffRemoved: "-",
+		vault.DiffChanged: "~",
+	}
+
+	counts := make(map[vault.DiffChange]int)
+	for _, entry := range report.Entries {
+		counts[entry.Change]++
+		fmt.Printf("  %s %-7s %s\n", symbols[entry.Change], entry.Kind, entry.Namespace+entry.Path)
+
+		for _, field := range entry.Fields {
+			if field.Field == "policy" {
+				fmt.Printf("      policy rules differ\n")
+				continue
+			}
+			fmt.Printf("      %s: %s → %s\n", field.Field, formatDiffValue(field.Left), formatDiffValue(field.Right))
+		}
+	}
+
+	fmt.Printf("\n  %d added, %d removed, %d changed\n", counts[vault.DiffAdded], counts[vault.DiffRemoved], counts[vault.DiffChanged])
+}
+
+func formatDiffValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	return fmt.Sprintf("%v", v)
+}
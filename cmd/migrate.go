@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"fmt"
+
+	"vault-migrator/pkg/vault"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateSourceAddr  string
+	migrateSourceToken string
+	migrateTargetAddr  string
+	migrateTargetToken string
+	migrateEngines     []string
+	migrateSkipPol     bool
+	migrateSkipAuth    bool
+	migrateSkipIdent   bool
+	migratePassword    string
+	migrateWorkers     int
+	migrateMaxRPS      float64
+	migrateRetries     int
+	migrateSourceNS    string
+	migrateTargetNS    string
+	migrateRecursive   bool
+	migrateNSMap       map[string]string
+	migrateRewrite     string
+	migrateDBOverride  string
+	migratePKIKeys     string
+	migrateAuthFields  string
+	migrateSecretIDs   string
+	migratePwMode      string
+	migrateCredsFile   string
+	migrateCredsPass   string
+	migrateCredsRcpts  []string
+	migratePwPolicy    string
+	migratePwCharset   string
+	migratePwLength    int
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy Vault data directly from one server to another",
+	Long:  `Stream all secrets (with versions), policies, auth methods, and configurations from a source Vault server into a target Vault server without writing an intermediate backup file.`,
+	RunE:  runMigrate,
+}
+
+func init() {
+	migrateCmd.Flags().StringVar(&migrateSourceAddr, "source-address", "", "Source Vault server address (or set VAULT_SOURCE_ADDR)")
+	migrateCmd.Flags().StringVar(&migrateSourceToken, "source-token", "", "Source Vault token (or set VAULT_SOURCE_TOKEN)")
+	migrateCmd.Flags().StringVar(&migrateTargetAddr, "target-address", "", "Target Vault server address (or set VAULT_TARGET_ADDR)")
+	migrateCmd.Flags().StringVar(&migrateTargetToken, "target-token", "", "Target Vault token (or set VAULT_TARGET_TOKEN)")
+	migrateCmd.Flags().StringVar(&migrateSourceNS, "source-namespace", "", "Source Vault namespace (or set VAULT_SOURCE_NAMESPACE)")
+	migrateCmd.Flags().StringVar(&migrateTargetNS, "target-namespace", "", "Target Vault namespace (or set VAULT_TARGET_NAMESPACE)")
+	migrateCmd.Flags().BoolVar(&migrateRecursive, "recursive", false, "Also migrate every child namespace")
+	migrateCmd.Flags().StringToStringVar(&migrateNSMap, "namespace-map", map[string]string{}, "Rename namespaces on the target, e.g. team-a=apps/team-a")
+	migrateCmd.Flags().StringVar(&migrateRewrite, "rewrite-rules", "", "JSON file with mount and secret path rewrite rules")
+	migrateCmd.Flags().StringVar(&migrateDBOverride, "database-overrides", "", "JSON file with database connection passwords and fields, keyed by <mount>/<connection>")
+	migrateCmd.Flags().StringVar(&migratePKIKeys, "pki-keys", "", "Directory with PKI issuer private keys as <mount>/<key name or ID>.pem")
+	migrateCmd.Flags().StringVar(&migrateAuthFields, "auth-overrides", "", "JSON file with write-only auth config fields such as bindpass, keyed by API path")
+	migrateCmd.Flags().StringVar(&migrateSecretIDs, "approle-secret-ids", "", "JSON file mapping approle secret ID accessors from the source to secret IDs to re-issue")
+	migrateCmd.Flags().StringSliceVarP(&migrateEngines, "engines", "e", []string{}, "Specific secret engines to migrate (empty = all)")
+	migrateCmd.Flags().BoolVar(&migrateSkipPol, "skip-policies", false, "Skip migrating policies")
+	migrateCmd.Flags().BoolVar(&migrateSkipAuth, "skip-auth", false, "Skip migrating auth methods")
+	migrateCmd.Flags().BoolVar(&migrateSkipIdent, "skip-identity", false, "Skip migrating identity entities and groups")
+	migrateCmd.Flags().StringVarP(&migratePassword, "default-password", "p", "ChangeMe123!", "Default password for migrated users")
+	migrateCmd.Flags().StringVar(&migratePwMode, "password-mode", string(vault.PasswordDefault), "Passwords of migrated userpass users: default, random or hash (copy hashes through sys/raw)")
+	migrateCmd.Flags().StringVar(&migratePwPolicy, "password-policy", "", "Vault password policy of the target that generates the random passwords")
+	migrateCmd.Flags().StringVar(&migratePwCharset, "password-charset", vault.DefaultPasswordCharset, "Characters of locally generated random passwords")
+	migrateCmd.Flags().IntVar(&migratePwLength, "password-length", vault.DefaultPasswordLength, "Length of locally generated random passwords")
+	migrateCmd.Flags().StringVar(&migrateCredsFile, "credentials-file", "vault-credentials", "Report of generated passwords")
+	migrateCmd.Flags().StringVar(&migrateCredsPass, "credentials-passphrase", "", "Encrypt the credentials report with a passphrase (or set VAULT_MIGRATOR_CREDENTIALS_PASSPHRASE)")
+	migrateCmd.Flags().StringSliceVar(&migrateCredsRcpts, "credentials-recipient", []string{}, "Encrypt the credentials report to an age public key (repeatable)")
+	migrateCmd.Flags().IntVar(&migrateWorkers, "concurrency", vault.DefaultConcurrency, "Number of secrets to copy in parallel")
+	migrateCmd.Flags().Float64Var(&migrateMaxRPS, "max-rps", 0, "Maximum requests per second to each Vault (0 = unlimited)")
+	migrateCmd.Flags().IntVar(&migrateRetries, "max-retries", vault.DefaultRequestOptions().MaxRetries, "Retries per request on 429 and 5xx responses")
+}
+
+func runMigrate(cmd *cobra.Command, args []string) error {
+	sourceAddr := getEnvOrFlag(migrateSourceAddr, "VAULT_SOURCE_ADDR")
+	sourceToken := getEnvOrFlag(migrateSourceToken, "VAULT_SOURCE_TOKEN")
+	targetAddr := getEnvOrFlag(migrateTargetAddr, "VAULT_TARGET_ADDR")
+	targetToken := getEnvOrFlag(migrateTargetToken, "VAULT_TARGET_TOKEN")
+
+	if sourceAddr == "" || sourceToken == "" {
+		return fmt.Errorf("source vault address and token are required")
+	}
+	if targetAddr == "" || targetToken == "" {
+		return fmt.Errorf("target vault address and token are required")
+	}
+
+	passwordMode, err := vault.ParsePasswordMode(migratePwMode)
+	if err != nil {
+		return err
+	}
+	credsEncryption := vault.EncryptionOptions{
+		Passphrase: getEnvOrFlag(migrateCredsPass, "VAULT_MIGRATOR_CREDENTIALS_PASSPHRASE"),
+		Recipients: migrateCredsRcpts,
+	}
+	credentials, credsPath, err := newCredentialsReport(passwordMode, migrateCredsFile, credsEncryption, false)
+	if err != nil {
+		return err
+	}
+
+	var rewrite *vault.RewriteRules
+	if migrateRewrite != "" {
+		rules, err := vault.LoadRewriteRules(migrateRewrite)
+		if err != nil {
+			return err
+		}
+		rewrite = rules
+	}
+
+	var dbOverrides vault.DatabaseOverrides
+	if migrateDBOverride != "" {
+		overrides, err := vault.LoadDatabaseOverrides(migrateDBOverride)
+		if err != nil {
+			return err
+		}
+		dbOverrides = overrides
+	}
+
+	var pkiKeys vault.PKIKeys
+	if migratePKIKeys != "" {
+		keys, err := vault.LoadPKIKeys(migratePKIKeys)
+		if err != nil {
+			return err
+		}
+		pkiKeys = keys
+	}
+
+	var authOverrides vault.AuthOverrides
+	if migrateAuthFields != "" {
+		overrides, err := vault.LoadAuthOverrides(migrateAuthFields)
+		if err != nil {
+			return err
+		}
+		authOverrides = overrides
+	}
+
+	var secretIDs vault.AppRoleSecretIDs
+	if migrateSecretIDs != "" {
+		ids, err := vault.LoadAppRoleSecretIDs(migrateSecretIDs)
+		if err != nil {
+			return err
+		}
+		secretIDs = ids
+	}
+
+	fmt.Printf("Connecting to source Vault at %s...\n", sourceAddr)
+	source, err := vault.NewClientWithOptions(sourceAddr, sourceToken, requestOptions(migrateMaxRPS, migrateRetries))
+	if err != nil {
+		return fmt.Errorf("failed to create source vault client: %w", err)
+	}
+	source.SetNamespace(getEnvOrFlag(migrateSourceNS, "VAULT_SOURCE_NAMESPACE"))
+
+	fmt.Printf("Connecting to target Vault at %s...\n", targetAddr)
+	target, err := vault.NewClientWithOptions(targetAddr, targetToken, requestOptions(migrateMaxRPS, migrateRetries))
+	if err != nil {
+		return fmt.Errorf("failed to create target vault client: %w", err)
+	}
+	target.SetNamespace(getEnvOrFlag(migrateTargetNS, "VAULT_TARGET_NAMESPACE"))
+
+	generator := vault.PasswordGenerator{
+		Policy:  migratePwPolicy,
+		Charset: migratePwCharset,
+		Length:  migratePwLength,
+	}
+	if passwordMode != vault.PasswordDefault {
+		if err := target.CheckPasswordGenerator(generator); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Starting migration...")
+
+	backupOpts := vault.BackupOptions{
+		Engines:      migrateEngines,
+		SkipPolicies: migrateSkipPol,
+		SkipAuth:     migrateSkipAuth,
+		SkipIdentity: migrateSkipIdent,
+		Recursive:    migrateRecursive,
+		Concurrency:  migrateWorkers,
+
+		// Hashes are copied from source to target storage
+		UserpassHashes: passwordMode == vault.PasswordHash,
+	}
+
+	opts := vault.RestoreOptions{
+		Engines:           migrateEngines,
+		SkipPolicies:      migrateSkipPol,
+		SkipAuth:          migrateSkipAuth,
+		SkipIdentity:      migrateSkipIdent,
+		DefaultPassword:   migratePassword,
+		Concurrency:       migrateWorkers,
+		NamespaceMap:      migrateNSMap,
+		Rewrite:           rewrite,
+		DatabaseOverrides: dbOverrides,
+		PKIKeys:           pkiKeys,
+		AuthOverrides:     authOverrides,
+		AppRoleSecretIDs:  secretIDs,
+		PasswordMode:      passwordMode,
+		Generator:         generator,
+		Credentials:       credentials,
+	}
+
+	stats, err := source.Migrate(target, backupOpts, opts)
+	if werr := writeCredentialsReport(credentials, credsPath, credsEncryption); werr != nil {
+		return werr
+	}
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	failed := source.Failures() + target.Failures()
+	if failed > 0 {
+		fmt.Printf("\n✗ Migration incomplete: %d reads or writes failed after retries\n", failed)
+	} else {
+		fmt.Printf("\n✓ Migration completed successfully!\n")
+	}
+	fmt.Printf("  Secret Engines: %d\n", stats.SecretEngines)
+	fmt.Printf("  Total Secrets: %d\n", stats.Secrets)
+	if !migrateSkipPol {
+		fmt.Printf("  Policies: %d\n", stats.Policies)
+	}
+	if !migrateSkipAuth {
+		fmt.Printf("  Auth Methods: %d\n", stats.AuthMethods)
+	}
+	if !migrateSkipIdent {
+		fmt.Printf("  Entities: %d\n", stats.Entities)
+		fmt.Printf("  Groups: %d\n", stats.Groups)
+	}
+	if stats.Namespaces > 0 {
+		fmt.Printf("  Namespaces: %d\n", stats.Namespaces)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d secrets or other entries could not be migrated", failed)
+	}
+	return nil
+}
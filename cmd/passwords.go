@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"vault-migrator/pkg/vault"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	passwordsAddr       string
+	passwordsToken      string
+	passwordsNS         string
+	passwordsFormat     string
+	passwordsMounts     []string
+	passwordsPass       string
+	passwordsIdentities []string
+	passwordsMaxRPS     float64
+	passwordsRetries    int
+)
+
+var passwordsCmd = &cobra.Command{
+	Use:   "passwords",
+	Short: "Manage userpass passwords",
+}
+
+var passwordsSetCmd = &cobra.Command{
+	Use:   "set [file]",
+	Short: "Set userpass passwords from a JSON or CSV file",
+	Long: `Set the passwords of existing userpass users. The file (or stdin when it is omitted or "-") is
+a JSON object of username:password pairs, a credentials report written by restore or migrate
+(optionally encrypted), or CSV rows of username,password[,mount]. Passwords without a mount are
+set in every userpass mount that has the user. Users that do not exist are never created.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPasswordsSet,
+}
+
+func init() {
+	passwordsSetCmd.Flags().StringVarP(&passwordsAddr, "address", "a", "", "Vault server address (or set VAULT_ADDR)")
+	passwordsSetCmd.Flags().StringVarP(&passwordsToken, "token", "t", "", "Vault token (or set VAULT_TOKEN)")
+	passwordsSetCmd.Flags().StringVarP(&passwordsNS, "namespace", "n", "", "Vault namespace (or set VAULT_NAMESPACE)")
+	passwordsSetCmd.Flags().StringVar(&passwordsFormat, "format", "", "Input format: json or csv (default: detected)")
+	passwordsSetCmd.Flags().StringSliceVarP(&passwordsMounts, "mounts", "m", []string{}, "Userpass mounts to update (empty = all)")
+	passwordsSetCmd.Flags().StringVar(&passwordsPass, "passphrase", "", "Passphrase for an encrypted credentials report (or set VAULT_MIGRATOR_CREDENTIALS_PASSPHRASE)")
+	passwordsSetCmd.Flags().StringSliceVarP(&passwordsIdentities, "identity", "i", []string{}, "age identity file for an encrypted credentials report (repeatable)")
+	passwordsSetCmd.Flags().Float64Var(&passwordsMaxRPS, "max-rps", 0, "Maximum requests per second to Vault (0 = unlimited)")
+	passwordsSetCmd.Flags().IntVar(&passwordsRetries, "max-retries", vault.DefaultRequestOptions().MaxRetries, "Retries per request on 429 and 5xx responses")
+
+	passwordsCmd.AddCommand(passwordsSetCmd)
+}
+
+func runPasswordsSet(cmd *cobra.Command, args []string) error {
+	addr := getEnvOrFlag(passwordsAddr, "VAULT_ADDR")
+	token := getEnvOrFlag(passwordsToken, "VAULT_TOKEN")
+
+	if addr == "" || token == "" {
+		return fmt.Errorf("vault address and token are required")
+	}
+
+	var input io.Reader = os.Stdin
+	if len(args) == 1 && args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read credentials file: %w", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	r, err := vault.NewDecryptingReader(input, vault.DecryptionOptions{
+		Passphrase:    getEnvOrFlag(passwordsPass, "VAULT_MIGRATOR_CREDENTIALS_PASSPHRASE"),
+		IdentityFiles: passwordsIdentities,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open credentials file: %w", err)
+	}
+	credentials, err := vault.ParseCredentials(r, passwordsFormat)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Connecting to Vault at %s...\n", addr)
+	client, err := vault.NewClientWithOptions(addr, token, requestOptions(passwordsMaxRPS, passwordsRetries))
+	if err != nil {
+		return fmt.Errorf("failed to create vault client: %w", err)
+	}
+	client.SetNamespace(getEnvOrFlag(passwordsNS, "VAULT_NAMESPACE"))
+
+	all, err := client.UserpassMounts()
+	if err != nil {
+		return err
+	}
+	mounts := all
+	if len(passwordsMounts) > 0 {
+		var selected []string
+		for _, mount := range passwordsMounts {
+			mount = strings.TrimSuffix(mount, "/")
+			if !contains(all, mount) {
+				return fmt.Errorf("%s is not a userpass mount (found: %s)", mount, strings.Join(all, ", "))
+			}
+			selected = append(selected, mount)
+		}
+		mounts = selected
+	}
+	if len(mounts) == 0 {
+		return fmt.Errorf("no userpass auth methods found")
+	}
+
+	fmt.Printf("Setting passwords for %d users in %s...\n\n", len(credentials), strings.Join(mounts, ", "))
+	results := client.SetUserpassPasswords(credentials, all, mounts)
+
+	failed := printPasswordResults(results, mounts)
+	if failed > 0 {
+		return fmt.Errorf("%d passwords could not be set", failed)
+	}
+	return nil
+}
+
+// printPasswordResults prints failures, users found in no mount and a table
+// of counts per mount, and returns the number of problems. Credentials for
+// mounts excluded with --mounts are only counted.
+func printPasswordResults(results []vault.PasswordResult, mounts []string) int {
+	type counts struct{ updated, notFound, failed int }
+	perMount := make(map[string]*counts)
+	found := make(map[string]bool)
+	var order []string
+	for _, mount := range mounts {
+		perMount[mount] = &counts{}
+		order = append(order, mount)
+	}
+
+	failed, filtered := 0, 0
+	for _, result := range results {
+		if result.Status == vault.PasswordFiltered {
+			found[result.Username] = true
+			filtered++
+			continue
+		}
+
+		c, ok := perMount[result.Mount]
+		if !ok {
+			c = &counts{}
+			perMount[result.Mount] = c
+			order = append(order, result.Mount)
+		}
+		switch result.Status {
+		case vault.PasswordUpdated:
+			c.updated++
+			found[result.Username] = true
+		case vault.PasswordNotFound:
+			c.notFound++
+		case vault.PasswordFailed:
+			c.failed++
+			found[result.Username] = true
+			failed++
+			fmt.Printf("✗ %s in %s: %v\n", result.Username, result.Mount, result.Err)
+		}
+	}
+
+	var missing []string
+	for _, result := range results {
+		if !found[result.Username] && !contains(missing, result.Username) {
+			missing = append(missing, result.Username)
+		}
+	}
+	for _, username := range missing {
+		fmt.Printf("✗ %s: user not found in any userpass mount\n", username)
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  MOUNT\tUPDATED\tNOT FOUND\tFAILED")
+	for _, mount := range order {
+		c := perMount[mount]
+		fmt.Fprintf(w, "  %s\t%d\t%d\t%d\n", mount, c.updated, c.notFound, c.failed)
+	}
+	w.Flush()
+	if filtered > 0 {
+		fmt.Printf("\nSkipped %d passwords for mounts not selected with --mounts\n", filtered)
+	}
+
+	return failed + len(missing)
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
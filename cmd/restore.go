@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 
@@ -17,7 +16,30 @@ var (
 	restoreEngines    []string
 	skipPolicies      bool
 	skipAuth          bool
+	skipIdentity      bool
 	defaultPassword   string
+	restorePass       string
+	restoreIdentities []string
+	restorePlan       bool
+	restoreJournal    string
+	restoreResume     bool
+	restoreWorkers    int
+	restoreMaxRPS     float64
+	restoreRetries    int
+	restoreNS         string
+	restoreNSMap      map[string]string
+	restoreRewrite    string
+	restoreDBOverride string
+	restorePKIKeys    string
+	restoreAuthFields string
+	restoreSecretIDs  string
+	restorePwMode     string
+	restoreCredsFile  string
+	restoreCredsPass  string
+	restoreCredsRcpts []string
+	restorePwPolicy   string
+	restorePwCharset  string
+	restorePwLength   int
 )
 
 var restoreCmd = &cobra.Command{
@@ -34,7 +56,30 @@ func init() {
 	restoreCmd.Flags().StringSliceVarP(&restoreEngines, "engines", "e", []string{}, "Specific secret engines to restore (empty = all)")
 	restoreCmd.Flags().BoolVar(&skipPolicies, "skip-policies", false, "Skip restoring policies")
 	restoreCmd.Flags().BoolVar(&skipAuth, "skip-auth", false, "Skip restoring auth methods")
+	restoreCmd.Flags().BoolVar(&skipIdentity, "skip-identity", false, "Skip restoring identity entities and groups")
 	restoreCmd.Flags().StringVarP(&defaultPassword, "default-password", "p", "ChangeMe123!", "Default password for restored users")
+	restoreCmd.Flags().StringVarP(&restoreNS, "namespace", "n", "", "Vault namespace to restore into (or set VAULT_NAMESPACE)")
+	restoreCmd.Flags().StringToStringVar(&restoreNSMap, "namespace-map", map[string]string{}, "Rename namespaces from the backup, e.g. team-a=apps/team-a")
+	restoreCmd.Flags().StringVar(&restoreRewrite, "rewrite-rules", "", "JSON file with mount and secret path rewrite rules")
+	restoreCmd.Flags().StringVar(&restoreDBOverride, "database-overrides", "", "JSON file with database connection passwords and fields, keyed by <mount>/<connection>")
+	restoreCmd.Flags().StringVar(&restorePKIKeys, "pki-keys", "", "Directory with PKI issuer private keys as <mount>/<key name or ID>.pem")
+	restoreCmd.Flags().StringVar(&restoreAuthFields, "auth-overrides", "", "JSON file with write-only auth config fields such as bindpass, keyed by API path")
+	restoreCmd.Flags().StringVar(&restoreSecretIDs, "approle-secret-ids", "", "JSON file mapping approle secret ID accessors from the backup to secret IDs to re-issue")
+	restoreCmd.Flags().StringVar(&restorePwMode, "password-mode", string(vault.PasswordDefault), "Passwords of restored userpass users: default, random or hash (copy hashes from a backup made with --userpass-hashes)")
+	restoreCmd.Flags().StringVar(&restorePwPolicy, "password-policy", "", "Vault password policy that generates the random passwords")
+	restoreCmd.Flags().StringVar(&restorePwCharset, "password-charset", vault.DefaultPasswordCharset, "Characters of locally generated random passwords")
+	restoreCmd.Flags().IntVar(&restorePwLength, "password-length", vault.DefaultPasswordLength, "Length of locally generated random passwords")
+	restoreCmd.Flags().StringVar(&restoreCredsFile, "credentials-file", "", "Report of generated passwords (default: <file>.credentials)")
+	restoreCmd.Flags().StringVar(&restoreCredsPass, "credentials-passphrase", "", "Encrypt the credentials report with a passphrase (or set VAULT_MIGRATOR_CREDENTIALS_PASSPHRASE)")
+	restoreCmd.Flags().StringSliceVar(&restoreCredsRcpts, "credentials-recipient", []string{}, "Encrypt the credentials report to an age public key (repeatable)")
+	restoreCmd.Flags().StringVar(&restorePass, "passphrase", "", "Passphrase for an encrypted backup (or set VAULT_MIGRATOR_PASSPHRASE)")
+	restoreCmd.Flags().BoolVar(&restorePlan, "plan", false, "Show what would change in the target Vault without writing anything")
+	restoreCmd.Flags().StringSliceVarP(&restoreIdentities, "identity", "i", []string{}, "age identity file for an encrypted backup (repeatable)")
+	restoreCmd.Flags().StringVar(&restoreJournal, "journal", "", "Checkpoint journal file (default: <file>.journal)")
+	restoreCmd.Flags().IntVar(&restoreWorkers, "concurrency", vault.DefaultConcurrency, "Number of secrets to write in parallel")
+	restoreCmd.Flags().Float64Var(&restoreMaxRPS, "max-rps", 0, "Maximum requests per second to Vault (0 = unlimited)")
+	restoreCmd.Flags().IntVar(&restoreRetries, "max-retries", vault.DefaultRequestOptions().MaxRetries, "Retries per request on 429 and 5xx responses")
+	restoreCmd.Flags().BoolVar(&restoreResume, "resume", false, "Resume an interrupted restore, skipping work recorded in the journal")
 }
 
 func runRestore(cmd *cobra.Command, args []string) error {
@@ -45,44 +90,248 @@ func runRestore(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("vault address and token are required")
 	}
 
-	data, err := os.ReadFile(restoreFile)
+	passwordMode, err := vault.ParsePasswordMode(restorePwMode)
 	if err != nil {
-		return fmt.Errorf("failed to read backup file: %w", err)
+		return err
 	}
 
-	var backup vault.BackupData
-	if err := json.Unmarshal(data, &backup); err != nil {
-		return fmt.Errorf("failed to parse backup file: %w", err)
+	decryption := vault.DecryptionOptions{
+		Passphrase:    getEnvOrFlag(restorePass, "VAULT_MIGRATOR_PASSPHRASE"),
+		IdentityFiles: restoreIdentities,
 	}
 
+	source, closeFile, err := openBackupFile(restoreFile, decryption)
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+
 	fmt.Printf("Connecting to Vault at %s...\n", addr)
-	client, err := vault.NewClient(addr, token)
+	client, err := vault.NewClientWithOptions(addr, token, requestOptions(restoreMaxRPS, restoreRetries))
 	if err != nil {
 		return fmt.Errorf("failed to create vault client: %w", err)
 	}
+	client.SetNamespace(getEnvOrFlag(restoreNS, "VAULT_NAMESPACE"))
 
-	fmt.Println("Starting restore process...")
-	
 	opts := vault.RestoreOptions{
 		Engines:         restoreEngines,
 		SkipPolicies:    skipPolicies,
 		SkipAuth:        skipAuth,
+		SkipIdentity:    skipIdentity,
 		DefaultPassword: defaultPassword,
+		PasswordMode:    passwordMode,
+		Concurrency:     restoreWorkers,
+		NamespaceMap:    restoreNSMap,
+		Generator: vault.PasswordGenerator{
+			Policy:  restorePwPolicy,
+			Charset: restorePwCharset,
+			Length:  restorePwLength,
+		},
+	}
+
+	if restoreRewrite != "" {
+		rules, err := vault.LoadRewriteRules(restoreRewrite)
+		if err != nil {
+			return err
+		}
+		opts.Rewrite = rules
+	}
+
+	if restoreDBOverride != "" {
+		overrides, err := vault.LoadDatabaseOverrides(restoreDBOverride)
+		if err != nil {
+			return err
+		}
+		opts.DatabaseOverrides = overrides
+	}
+
+	if restorePKIKeys != "" {
+		keys, err := vault.LoadPKIKeys(restorePKIKeys)
+		if err != nil {
+			return err
+		}
+		opts.PKIKeys = keys
+	}
+
+	if restoreAuthFields != "" {
+		overrides, err := vault.LoadAuthOverrides(restoreAuthFields)
+		if err != nil {
+			return err
+		}
+		opts.AuthOverrides = overrides
+	}
+
+	if restoreSecretIDs != "" {
+		secretIDs, err := vault.LoadAppRoleSecretIDs(restoreSecretIDs)
+		if err != nil {
+			return err
+		}
+		opts.AppRoleSecretIDs = secretIDs
+	}
+
+	if restorePlan {
+		fmt.Println("Planning restore (no changes will be made)...")
+		plan, err := client.Plan(source, opts)
+		if err != nil {
+			return fmt.Errorf("plan failed: %w", err)
+		}
+		printPlan(plan)
+		return nil
+	}
+
+	journalPath := restoreJournal
+	if journalPath == "" {
+		journalPath = restoreFile + ".journal"
+	}
+	journal, err := vault.OpenJournal(journalPath, restoreResume)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	fmt.Printf("Recording progress in %s\n", journalPath)
+	if restoreResume {
+		fmt.Printf("Resuming restore: %d items already completed\n", journal.Completed())
+	}
+	opts.Journal = journal
+
+	credsPath := restoreCredsFile
+	if credsPath == "" {
+		credsPath = restoreFile + ".credentials"
+	}
+	credsEncryption := vault.EncryptionOptions{
+		Passphrase: getEnvOrFlag(restoreCredsPass, "VAULT_MIGRATOR_CREDENTIALS_PASSPHRASE"),
+		Recipients: restoreCredsRcpts,
+	}
+	credentials, credsPath, err := newCredentialsReport(passwordMode, credsPath, credsEncryption, restoreResume)
+	if err != nil {
+		return err
+	}
+	opts.Credentials = credentials
+	if passwordMode != vault.PasswordDefault {
+		if err := client.CheckPasswordGenerator(opts.Generator); err != nil {
+			return err
+		}
 	}
 
-	if err := client.Restore(&backup, opts); err != nil {
-		return fmt.Errorf("restore failed: %w", err)
+	fmt.Println("Starting restore process...")
+	
+	stats, err := client.Restore(source, opts)
+	if werr := writeCredentialsReport(credentials, credsPath, credsEncryption); werr != nil {
+		return werr
+	}
+	if err != nil {
+		return fmt.Errorf("restore failed (rerun with --resume to continue): %w", err)
 	}
 
-	fmt.Printf("\n✓ Restore completed successfully!\n")
-	fmt.Printf("  Secret Engines: %d\n", len(backup.SecretEngines))
-	fmt.Printf("  Total Secrets: %d\n", countSecrets(&backup))
+	failed := client.Failures()
+	if failed > 0 {
+		fmt.Printf("\n✗ Restore incomplete: %d writes failed after retries\n", failed)
+	} else {
+		fmt.Printf("\n✓ Restore completed successfully!\n")
+	}
+	fmt.Printf("  Secret Engines: %d\n", stats.SecretEngines)
+	fmt.Printf("  Total Secrets: %d\n", stats.Secrets)
 	if !skipPolicies {
-		fmt.Printf("  Policies: %d\n", len(backup.Policies))
+		fmt.Printf("  Policies: %d\n", stats.Policies)
 	}
 	if !skipAuth {
-		fmt.Printf("  Auth Methods: %d\n", len(backup.AuthMethods))
+		fmt.Printf("  Auth Methods: %d\n", stats.AuthMethods)
+	}
+	if !skipIdentity {
+		fmt.Printf("  Entities: %d\n", stats.Entities)
+		fmt.Printf("  Groups: %d\n", stats.Groups)
+	}
+	if stats.Namespaces > 0 {
+		fmt.Printf("  Namespaces: %d\n", stats.Namespaces)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d secrets or other entries could not be restored; rerun with --resume to retry them", failed)
+	}
+	return nil
+}
+
+// newCredentialsReport checks that the report of generated passwords can be
+// written before anything is restored, and returns the path to write it to.
+// When resuming, the report of the interrupted run is kept and this run's
+// passwords go to the first free <path>.1, <path>.2, ...
+func newCredentialsReport(mode vault.PasswordMode, path string, encryption vault.EncryptionOptions, resume bool) (*vault.CredentialsReport, string, error) {
+	if mode == vault.PasswordDefault {
+		return nil, path, nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		if !resume {
+			return nil, "", fmt.Errorf("credentials report %s already exists; move it away or set --credentials-file (with --resume a numbered report is written next to it)", path)
+		}
+		earlier := path
+		for i := 1; ; i++ {
+			path = fmt.Sprintf("%s.%d", earlier, i)
+			if _, err := os.Stat(path); err != nil {
+				break
+			}
+		}
+		fmt.Printf("Credentials report %s exists; passwords generated by this run will be written to %s\n", earlier, path)
+	}
+	if !encryption.Enabled() {
+		fmt.Printf("Warning: generated passwords will be written to %s in plaintext; consider --credentials-passphrase or --credentials-recipient\n", path)
 	}
+	return &vault.CredentialsReport{}, path, nil
+}
 
+// writeCredentialsReport is called even when the restore failed, so the
+// passwords of users restored so far are not lost
+func writeCredentialsReport(report *vault.CredentialsReport, path string, encryption vault.EncryptionOptions) error {
+	if report == nil || report.Len() == 0 {
+		return nil
+	}
+	if err := report.WriteFile(path, encryption); err != nil {
+		return err
+	}
+	fmt.Printf("\nWrote %d generated passwords to %s\n", report.Len(), path)
 	return nil
 }
+
+func printPlan(plan *vault.Plan) {
+	symbols := map[vault.PlanAction]string{
+		vault.PlanCreate:   "+",
+		vault.PlanUpdate:   "~",
+		vault.PlanSkip:     "=",
+		vault.PlanConflict: "!",
+	}
+
+	fmt.Println()
+	for _, item := range plan.Items {
+		line := fmt.Sprintf("  %s %-8s %-7s %s", symbols[item.Action], item.Action, item.Kind, item.Namespace+item.Path)
+		if item.Detail != "" {
+			line += " (" + item.Detail + ")"
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Printf("\nPlan: %d to create, %d to update, %d unchanged, %d conflicts\n",
+		plan.Count(vault.PlanCreate), plan.Count(vault.PlanUpdate), plan.Count(vault.PlanSkip), plan.Count(vault.PlanConflict))
+}
+
+// openBackupFile decrypts the file if needed and returns a source that reads
+// it one record at a time. Legacy single-document backups are also accepted.
+func openBackupFile(path string, decryption vault.DecryptionOptions) (vault.BackupSource, func() error, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read backup file: %w", err)
+	}
+
+	r, err := vault.NewDecryptingReader(f, decryption)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to open backup file: %w", err)
+	}
+
+	source, err := vault.OpenBackupSource(r)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to parse backup file: %w", err)
+	}
+
+	return source, f.Close, nil
+}
@@ -17,4 +17,7 @@ func Execute() error {
 func init() {
 	rootCmd.AddCommand(backupCmd)
 	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(passwordsCmd)
 }
@@ -0,0 +1,232 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// AppRoleBackup holds the role ID and secret IDs of an approle role. Vault
+// never returns secret IDs, only their accessors and settings.
+type AppRoleBackup struct {
+	Name      string            `json:"name"`
+	RoleID    string            `json:"role_id"`
+	SecretIDs []AppRoleSecretID `json:"secret_ids,omitempty"`
+}
+
+type AppRoleSecretID struct {
+	Accessor        string            `json:"accessor"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	CIDRList        []string          `json:"cidr_list,omitempty"`
+	TokenBoundCIDRs []string          `json:"token_bound_cidrs,omitempty"`
+	NumUses         int               `json:"num_uses,omitempty"`
+	ExpirationTime  time.Time         `json:"expiration_time"`
+}
+
+// AppRoleSecretIDs maps secret ID accessors from the backup to the secret
+// IDs, which are re-issued on restore as custom secret IDs
+type AppRoleSecretIDs map[string]string
+
+func LoadAppRoleSecretIDs(path string) (AppRoleSecretIDs, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read approle secret IDs: %w", err)
+	}
+
+	var secretIDs AppRoleSecretIDs
+	if err := json.Unmarshal(data, &secretIDs); err != nil {
+		return nil, fmt.Errorf("failed to parse approle secret IDs: %w", err)
+	}
+	return secretIDs, nil
+}
+
+func (c *Client) backupAppRoleIDs(authPath string, roles []RoleBackup) ([]AppRoleBackup, error) {
+	basePath := authBasePath(authPath) + "/role/"
+
+	var approles []AppRoleBackup
+	secretIDs := 0
+	for _, role := range roles {
+		rolePath := basePath + role.Name
+		approle := AppRoleBackup{Name: role.Name}
+
+		resp, err := c.client.Logical().Read(rolePath + "/role-id")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read role ID of %s: %w", role.Name, err)
+		}
+		if resp != nil && resp.Data != nil {
+			approle.RoleID = stringValue(resp.Data["role_id"])
+		}
+
+		accessors, err := c.listKeys(rolePath + "/secret-id")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secret IDs of %s: %w", role.Name, err)
+		}
+		for _, accessor := range accessors {
+			resp, err := c.client.Logical().Write(rolePath+"/secret-id-accessor/lookup", map[string]interface{}{
+				"secret_id_accessor": accessor,
+			})
+			if err != nil || resp == nil || resp.Data == nil {
+				fmt.Printf("    Warning: failed to look up secret ID accessor %s of %s: %v\n", accessor, role.Name, err)
+				continue
+			}
+			approle.SecretIDs = append(approle.SecretIDs, parseSecretID(accessor, resp.Data))
+		}
+		secretIDs += len(approle.SecretIDs)
+
+		approles = append(approles, approle)
+	}
+
+	fmt.Printf("    Backed up %d role IDs and %d secret ID accessors (secret IDs are not included)\n", len(approles), secretIDs)
+	return approles, nil
+}
+
+func parseSecretID(accessor string, data map[string]interface{}) AppRoleSecretID {
+	secretID := AppRoleSecretID{
+		Accessor:        accessor,
+		Metadata:        toStringMap(data["metadata"]),
+		CIDRList:        toStringSlice(data["cidr_list"]),
+		TokenBoundCIDRs: toStringSlice(data["token_bound_cidrs"]),
+	}
+	secretID.NumUses, _ = strconv.Atoi(fmt.Sprintf("%v", data["secret_id_num_uses"]))
+	secretID.ExpirationTime, _ = time.Parse(time.RFC3339, stringValue(data["expiration_time"]))
+	return secretID
+}
+
+// restoreAppRoleIDs sets the role IDs from the backup and re-issues the
+// secret IDs found in secretIDs. The accessors of all other secret IDs are
+// listed, since the apps using them cannot log in to the target.
+func (c *Client) restoreAppRoleIDs(authPath string, approles []AppRoleBackup, secretIDs AppRoleSecretIDs, journal *Journal) error {
+	basePath := authBasePath(authPath) + "/role/"
+
+	reissued := 0
+	rotate := make(map[string][]string)
+	for _, approle := range approles {
+		rolePath := basePath + approle.Name
+
+		if approle.RoleID != "" && !journal.Done(journalKey("role-id", rolePath)) {
+			_, err := c.client.Logical().Write(rolePath+"/role-id", map[string]interface{}{
+				"role_id": approle.RoleID,
+			})
+			if err != nil {
+				c.reportFailure("      Warning: failed to restore role ID of %s: %v\n", approle.Name, err)
+			} else if err := journal.Record(journalKey("role-id", rolePath)); err != nil {
+				return err
+			}
+		}
+
+		for _, secretID := range approle.SecretIDs {
+			value, ok := secretIDs[secretID.Accessor]
+			if !ok {
+				rotate[approle.Name] = append(rotate[approle.Name], secretID.Accessor)
+				continue
+			}
+
+			key := journalKey("secret-id", rolePath+"/"+secretID.Accessor)
+			if journal.Done(key) {
+				continue
+			}
+			if err := c.reissueSecretID(rolePath, secretID, value); err != nil {
+				c.reportFailure("      Warning: failed to re-issue secret ID %s of %s: %v\n", secretID.Accessor, approle.Name, err)
+				rotate[approle.Name] = append(rotate[approle.Name], secretID.Accessor)
+				continue
+			}
+			if err := journal.Record(key); err != nil {
+				return err
+			}
+			reissued++
+		}
+	}
+
+	if reissued > 0 {
+		fmt.Printf("    Re-issued %d secret IDs\n", reissued)
+	}
+	if len(rotate) > 0 {
+		fmt.Printf("    Warning: these secret IDs were not re-issued and must be rotated (supply them with --approle-secret-ids to keep them):\n")
+		for _, approle := range approles {
+			for _, accessor := range rotate[approle.Name] {
+				fmt.Printf("      %s%s: %s\n", basePath, approle.Name, accessor)
+			}
+		}
+	}
+	return nil
+}
+
+// reissueSecretID creates value as a custom secret ID with the settings of
+// the original. Its remaining TTL is kept, so an expired one is not created.
+func (c *Client) reissueSecretID(rolePath string, secretID AppRoleSecretID, value string) error {
+	data := map[string]interface{}{
+		"secret_id": value,
+	}
+	if len(secretID.Metadata) > 0 {
+		metadata, err := json.Marshal(secretID.Metadata)
+		if err != nil {
+			return err
+		}
+		data["metadata"] = string(metadata)
+	}
+	if len(secretID.CIDRList) > 0 {
+		data["cidr_list"] = secretID.CIDRList
+	}
+	if len(secretID.TokenBoundCIDRs) > 0 {
+		data["token_bound_cidrs"] = secretID.TokenBoundCIDRs
+	}
+	if secretID.NumUses > 0 {
+		data["num_uses"] = secretID.NumUses
+	}
+	if !secretID.ExpirationTime.IsZero() {
+		ttl := time.Until(secretID.ExpirationTime)
+		if ttl <= 0 {
+			return fmt.Errorf("expired at %s", secretID.ExpirationTime.Format(time.RFC3339))
+		}
+		data["ttl"] = fmt.Sprintf("%ds", int(ttl.Seconds()))
+	}
+
+	_, err := c.client.Logical().Write(rolePath+"/custom-secret-id", data)
+	return err
+}
+
+func diffAppRoleIDs(report *DiffReport, authPath string, left, right AuthMethodBackup) {
+	diffAuthEntries(report, "approle", authPath, appRolesToMap(left.AppRoles), appRolesToMap(right.AppRoles))
+}
+
+func appRolesToMap(approles []AppRoleBackup) map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	for _, approle := range approles {
+		result[approle.Name] = map[string]interface{}{
+			"role_id":    approle.RoleID,
+			"secret_ids": len(approle.SecretIDs),
+		}
+	}
+	return result
+}
+
+func planAppRoleIDs(p *PlanContext, auth AuthMethodBackup) {
+	secretIDs := p.Options().AppRoleSecretIDs
+	for _, approle := range auth.AppRoles {
+		rolePath := authBasePath(auth.Path) + "/role/" + approle.Name
+
+		if approle.RoleID != "" {
+			resp, err := p.Read(rolePath + "/role-id")
+			switch {
+			case err != nil:
+				p.Add("role id", rolePath, PlanConflict, err.Error())
+			case resp == nil || resp.Data == nil:
+				p.Add("role id", rolePath, PlanCreate, "")
+			case stringValue(resp.Data["role_id"]) == approle.RoleID:
+				p.Add("role id", rolePath, PlanSkip, "identical")
+			default:
+				p.Add("role id", rolePath, PlanUpdate, "role_id differs")
+			}
+		}
+
+		for _, secretID := range approle.SecretIDs {
+			if _, ok := secretIDs[secretID.Accessor]; ok {
+				p.Add("secret id", rolePath+"/"+secretID.Accessor, PlanCreate, "would be re-issued")
+			} else {
+				p.Add("secret id", rolePath+"/"+secretID.Accessor, PlanSkip, "not supplied, needs rotation")
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	RegisterAuthHandler("userpass", userpassHandler{})
+	RegisterAuthHandler("approle", approleHandler{})
+	RegisterAuthHandler("ldap", ldapHandler{})
+	RegisterAuthHandler("cert", certHandler{})
+}
+
+// authBasePath is the API path of an auth mount, e.g. auth/userpass
+func authBasePath(authPath string) string {
+	return "auth/" + strings.TrimSuffix(authPath, "/")
+}
+
+// userpassHandler carries users. Their passwords are set as selected by
+// RestoreOptions.PasswordMode.
+type userpassHandler struct{}
+
+func (userpassHandler) Backup(c *Client, auth *AuthMethodBackup) error {
+	users, err := c.backupUserpassUsers(auth.Path)
+	if err != nil {
+		return fmt.Errorf("failed to backup userpass users: %w", err)
+	}
+	auth.Users = users
+	return nil
+}
+
+func (userpassHandler) Restore(c *Client, auth AuthMethodBackup, opts RestoreOptions) error {
+	if err := c.restoreUserpassUsers(auth.Path, auth.Users, opts); err != nil {
+		return fmt.Errorf("failed to restore userpass users: %w", err)
+	}
+	return nil
+}
+
+func (userpassHandler) Diff(report *DiffReport, authPath string, left, right AuthMethodBackup) {
+	diffAuthEntries(report, "user", authPath, usersToMap(left.Users), usersToMap(right.Users))
+}
+
+func (userpassHandler) Plan(p *PlanContext, auth AuthMethodBackup) {
+	for _, user := range auth.Users {
+		detail := "password would be reset"
+		switch p.Options().PasswordMode {
+		case PasswordRandom:
+			detail = "password would be generated"
+		case PasswordHash:
+			if user.PasswordHash != "" {
+				detail = "password hash would be copied"
+			} else {
+				detail = "no password hash, password would be generated"
+			}
+		}
+		p.Entry("user", authBasePath(auth.Path)+"/users/"+user.Name, user.Data, detail)
+	}
+}
+
+type approleHandler struct{}
+
+func (approleHandler) Backup(c *Client, auth *AuthMethodBackup) error {
+	roles, err := c.backupAppRoles(auth.Path)
+	if err != nil {
+		return fmt.Errorf("failed to backup approles: %w", err)
+	}
+	auth.Roles = roles
+
+	approles, err := c.backupAppRoleIDs(auth.Path, roles)
+	if err != nil {
+		return fmt.Errorf("failed to backup approle IDs: %w", err)
+	}
+	auth.AppRoles = approles
+	return nil
+}
+
+func (approleHandler) Restore(c *Client, auth AuthMethodBackup, opts RestoreOptions) error {
+	if err := c.restoreAppRoles(auth.Path, auth.Roles, opts.Journal); err != nil {
+		return fmt.Errorf("failed to restore approles: %w", err)
+	}
+	if err := c.restoreAppRoleIDs(auth.Path, auth.AppRoles, opts.AppRoleSecretIDs, opts.Journal); err != nil {
+		return fmt.Errorf("failed to restore approle IDs: %w", err)
+	}
+	return nil
+}
+
+func (approleHandler) Diff(report *DiffReport, authPath string, left, right AuthMethodBackup) {
+	diffAuthEntries(report, "role", authPath, rolesToMap(left.Roles), rolesToMap(right.Roles))
+	diffAppRoleIDs(report, authPath, left, right)
+}
+
+func (approleHandler) Plan(p *PlanContext, auth AuthMethodBackup) {
+	for _, role := range auth.Roles {
+		p.Entry("role", authBasePath(auth.Path)+"/role/"+role.Name, role.Data, "")
+	}
+	planAppRoleIDs(p, auth)
+}
+
+// ldapHandler carries the connection config, users and groups
+type ldapHandler struct{}
+
+func (ldapHandler) Backup(c *Client, auth *AuthMethodBackup) error {
+	c.backupAuthConfigs(auth, ldapConfig)
+
+	users, err := c.backupLDAPUsers(auth.Path)
+	if err != nil {
+		return fmt.Errorf("failed to backup LDAP users: %w", err)
+	}
+	auth.Users = users
+
+	groups, err := c.backupLDAPGroups(auth.Path)
+	if err != nil {
+		return fmt.Errorf("failed to backup LDAP groups: %w", err)
+	}
+	auth.Groups = groups
+	return nil
+}
+
+func (ldapHandler) Restore(c *Client, auth AuthMethodBackup, opts RestoreOptions) error {
+	c.restoreAuthConfigs(auth, opts.AuthOverrides)
+	if err := c.restoreLDAPUsers(auth.Path, auth.Users, opts.Journal); err != nil {
+		return fmt.Errorf("failed to restore LDAP users: %w", err)
+	}
+	if err := c.restoreLDAPGroups(auth.Path, auth.Groups, opts.Journal); err != nil {
+		return fmt.Errorf("failed to restore LDAP groups: %w", err)
+	}
+	return nil
+}
+
+func (ldapHandler) Diff(report *DiffReport, authPath string, left, right AuthMethodBackup) {
+	diffAuthConfigs(report, authPath, left, right)
+	diffAuthEntries(report, "user", authPath, usersToMap(left.Users), usersToMap(right.Users))
+	diffAuthEntries(report, "ldap group", authPath+"groups/", rolesToMap(left.Groups), rolesToMap(right.Groups))
+}
+
+func (ldapHandler) Plan(p *PlanContext, auth AuthMethodBackup) {
+	planAuthConfigs(p, auth)
+	for _, user := range auth.Users {
+		p.Entry("user", authBasePath(auth.Path)+"/users/"+user.Name, user.Data, "")
+	}
+	for _, group := range auth.Groups {
+		p.Entry("ldap group", authBasePath(auth.Path)+"/groups/"+group.Name, group.Data, "")
+	}
+}
+
+// certHandler carries the trusted certificates (certs/<name>) and config
+type certHandler struct{}
+
+func (certHandler) Backup(c *Client, auth *AuthMethodBackup) error {
+	c.backupAuthConfigs(auth, certConfig)
+
+	certs, err := c.readEntries(authBasePath(auth.Path)+"/certs", authBasePath(auth.Path)+"/certs/", nil)
+	if err != nil {
+		return fmt.Errorf("failed to backup certs: %w", err)
+	}
+	auth.Roles = certs
+	return nil
+}
+
+func (certHandler) Restore(c *Client, auth AuthMethodBackup, opts RestoreOptions) error {
+	c.restoreAuthConfigs(auth, opts.AuthOverrides)
+	if err := c.restoreAuthRoles(authBasePath(auth.Path)+"/certs/", auth.Roles, opts.Journal); err != nil {
+		return fmt.Errorf("failed to restore certs: %w", err)
+	}
+	return nil
+}
+
+func (certHandler) Diff(report *DiffReport, authPath string, left, right AuthMethodBackup) {
+	diffAuthConfigs(report, authPath, left, right)
+	diffAuthEntries(report, "cert", authPath+"certs/", rolesToMap(left.Roles), rolesToMap(right.Roles))
+}
+
+func (certHandler) Plan(p *PlanContext, auth AuthMethodBackup) {
+	planAuthConfigs(p, auth)
+	for _, cert := range auth.Roles {
+		p.Entry("cert", authBasePath(auth.Path)+"/certs/"+cert.Name, cert.Data, "")
+	}
+}
+
+// restoreAuthRoles writes each entry to basePath+name, skipping entries a
+// previous run restored
+func (c *Client) restoreAuthRoles(basePath string, roles []RoleBackup, journal *Journal) error {
+	for _, role := range roles {
+		rolePath := basePath + role.Name
+		if journal.Done(journalKey("role", rolePath)) {
+			continue
+		}
+		if _, err := c.client.Logical().Write(rolePath, role.Data); err != nil {
+			c.reportFailure("      Warning: failed to restore %s: %v\n", rolePath, err)
+			continue
+		}
+		if err := journal.Record(journalKey("role", rolePath)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
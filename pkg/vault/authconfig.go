@@ -0,0 +1,192 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func init() {
+	RegisterAuthHandler("jwt", configAuthHandler{jwtConfig, []string{"role"}})
+	RegisterAuthHandler("oidc", configAuthHandler{jwtConfig, []string{"role"}})
+	RegisterAuthHandler("kubernetes", configAuthHandler{kubernetesConfig, []string{"role"}})
+	RegisterAuthHandler("github", configAuthHandler{githubConfig, []string{"map/teams", "map/users"}})
+	RegisterAuthHandler("aws", configAuthHandler{awsConfig, []string{"role"}})
+	RegisterAuthHandler("azure", configAuthHandler{azureConfig, []string{"role"}})
+	RegisterAuthHandler("gcp", configAuthHandler{gcpConfig, []string{"role"}})
+	RegisterAuthHandler("okta", configAuthHandler{oktaConfig, []string{"groups", "users"}})
+	RegisterAuthHandler("radius", configAuthHandler{radiusConfig, []string{"users"}})
+}
+
+// authConfigEndpoint is a config endpoint below auth/<path>/. Sensitive
+// fields are write-only in Vault, so they are never in a backup and must be
+// supplied on restore.
+type authConfigEndpoint struct {
+	Path      string
+	Sensitive []string
+}
+
+var (
+	ldapConfig       = []authConfigEndpoint{{"config", []string{"bindpass", "client_tls_key"}}}
+	jwtConfig        = []authConfigEndpoint{{"config", []string{"oidc_client_secret"}}}
+	kubernetesConfig = []authConfigEndpoint{{"config", []string{"token_reviewer_jwt"}}}
+	certConfig       = []authConfigEndpoint{{"config", nil}}
+	githubConfig     = []authConfigEndpoint{{"config", nil}}
+	awsConfig        = []authConfigEndpoint{{"config/client", []string{"secret_key"}}}
+	azureConfig      = []authConfigEndpoint{{"config", []string{"client_secret"}}}
+	gcpConfig        = []authConfigEndpoint{{"config", []string{"credentials"}}}
+	oktaConfig       = []authConfigEndpoint{{"config", []string{"api_token", "token"}}}
+	radiusConfig     = []authConfigEndpoint{{"config", []string{"secret"}}}
+)
+
+// AuthConfigBackup is one config endpoint of an auth method. Missing lists
+// the sensitive fields Vault did not return, which need operator input.
+type AuthConfigBackup struct {
+	Endpoint string                 `json:"endpoint"`
+	Data     map[string]interface{} `json:"data"`
+	Missing  []string               `json:"missing,omitempty"`
+}
+
+// AuthOverrides supplies auth config fields on restore, keyed by the API path
+// of the endpoint, e.g. "auth/ldap/config" => {"bindpass": "..."}
+type AuthOverrides map[string]map[string]interface{}
+
+func LoadAuthOverrides(path string) (AuthOverrides, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth overrides: %w", err)
+	}
+
+	var overrides AuthOverrides
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse auth overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// backupAuthConfigs reads the config endpoints. A failed read is reported
+// and skipped, so the method's roles and users are still backed up.
+func (c *Client) backupAuthConfigs(auth *AuthMethodBackup, endpoints []authConfigEndpoint) {
+	for _, endpoint := range endpoints {
+		path := authBasePath(auth.Path) + "/" + endpoint.Path
+		resp, err := c.client.Logical().Read(path)
+		if err != nil {
+			c.reportFailure("    Warning: failed to read %s: %v\n", path, err)
+			continue
+		}
+		if resp == nil || resp.Data == nil {
+			continue
+		}
+
+		config := AuthConfigBackup{Endpoint: endpoint.Path, Data: resp.Data}
+		for _, field := range endpoint.Sensitive {
+			if _, ok := resp.Data[field]; !ok {
+				config.Missing = append(config.Missing, field)
+			}
+		}
+		auth.Configs = append(auth.Configs, config)
+	}
+}
+
+// restoreAuthConfigs writes the config endpoints with the operator's
+// overrides applied, and lists sensitive fields that are still unset. A failed
+// write is reported and the remaining entries of the method are restored.
+func (c *Client) restoreAuthConfigs(auth AuthMethodBackup, overrides AuthOverrides) {
+	for _, config := range auth.Configs {
+		path := authBasePath(auth.Path) + "/" + config.Endpoint
+
+		data := make(map[string]interface{})
+		for k, v := range config.Data {
+			data[k] = v
+		}
+		for k, v := range overrides[path] {
+			data[k] = v
+		}
+
+		if _, err := c.client.Logical().Write(path, data); err != nil {
+			c.reportFailure("    Warning: failed to restore %s: %v\n", path, err)
+			continue
+		}
+
+		var unset []string
+		for _, field := range config.Missing {
+			if _, ok := overrides[path][field]; !ok {
+				unset = append(unset, field)
+			}
+		}
+		if len(unset) > 0 {
+			fmt.Printf("    Warning: %s was restored without %v; if they were set in the source, supply them with --auth-overrides\n", path, unset)
+		}
+	}
+}
+
+func diffAuthConfigs(report *DiffReport, authPath string, left, right AuthMethodBackup) {
+	diffAuthEntries(report, "auth config", authPath, authConfigsToMap(left.Configs), authConfigsToMap(right.Configs))
+}
+
+func authConfigsToMap(configs []AuthConfigBackup) map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	for _, config := range configs {
+		result[config.Endpoint] = config.Data
+	}
+	return result
+}
+
+func planAuthConfigs(p *PlanContext, auth AuthMethodBackup) {
+	for _, config := range auth.Configs {
+		path := authBasePath(auth.Path) + "/" + config.Endpoint
+		alwaysUpdate := ""
+		if len(config.Missing) > 0 {
+			alwaysUpdate = fmt.Sprintf("%v would need operator input", config.Missing)
+		}
+		p.Entry("auth config", path, config.Data, alwaysUpdate)
+	}
+}
+
+// configAuthHandler handles auth types whose state is their config and the
+// entries below a few list endpoints, such as role/ or map/teams/. Entries
+// are kept in Roles, named by their path below the mount.
+type configAuthHandler struct {
+	endpoints []authConfigEndpoint
+	entries   []string
+}
+
+func (h configAuthHandler) Backup(c *Client, auth *AuthMethodBackup) error {
+	c.backupAuthConfigs(auth, h.endpoints)
+
+	basePath := authBasePath(auth.Path) + "/"
+	for _, prefix := range h.entries {
+		entries, err := c.readEntries(basePath+prefix, basePath+prefix+"/", nil)
+		if err != nil {
+			return fmt.Errorf("failed to backup %s: %w", basePath+prefix, err)
+		}
+		for _, entry := range entries {
+			entry.Name = prefix + "/" + entry.Name
+			auth.Roles = append(auth.Roles, entry)
+		}
+	}
+	if len(h.entries) > 0 {
+		fmt.Printf("    Backed up %d roles and mappings\n", len(auth.Roles))
+	}
+	return nil
+}
+
+func (h configAuthHandler) Restore(c *Client, auth AuthMethodBackup, opts RestoreOptions) error {
+	c.restoreAuthConfigs(auth, opts.AuthOverrides)
+	if err := c.restoreAuthRoles(authBasePath(auth.Path)+"/", auth.Roles, opts.Journal); err != nil {
+		return fmt.Errorf("failed to restore roles: %w", err)
+	}
+	return nil
+}
+
+func (h configAuthHandler) Diff(report *DiffReport, authPath string, left, right AuthMethodBackup) {
+	diffAuthConfigs(report, authPath, left, right)
+	diffAuthEntries(report, "role", authPath, rolesToMap(left.Roles), rolesToMap(right.Roles))
+}
+
+func (h configAuthHandler) Plan(p *PlanContext, auth AuthMethodBackup) {
+	planAuthConfigs(p, auth)
+	for _, role := range auth.Roles {
+		p.Entry("role", authBasePath(auth.Path)+"/"+role.Name, role.Data, "")
+	}
+}
@@ -3,7 +3,10 @@ package vault
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/vault/api"
@@ -11,11 +14,19 @@ import (
 
 type Client struct {
 	client *api.Client
+
+	// failures counts secrets that failed after all retries
+	failures int64
 }
 
 func NewClient(address, token string) (*Client, error) {
+	return NewClientWithOptions(address, token, DefaultRequestOptions())
+}
+
+func NewClientWithOptions(address, token string, opts RequestOptions) (*Client, error) {
 	config := api.DefaultConfig()
 	config.Address = address
+	configureRequests(config, opts)
 
 	client, err := api.NewClient(config)
 	if err != nil {
@@ -27,45 +38,84 @@ func NewClient(address, token string) (*Client, error) {
 	return &Client{client: client}, nil
 }
 
-func (c *Client) Backup(engines []string) (*BackupData, error) {
-	backup := &BackupData{
+func (c *Client) Backup(opts BackupOptions, sink BackupSink) error {
+	header := BackupHeader{
+		Format:    StreamFormat,
 		Timestamp: time.Now(),
+		Engines:   opts.Engines,
 	}
 
 	// Get Vault version
 	health, err := c.client.Sys().Health()
 	if err == nil {
-		backup.VaultVersion = health.Version
+		header.VaultVersion = health.Version
+	}
+
+	if err := sink.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if err := c.backupNamespace(opts, sink); err != nil {
+		return err
+	}
+
+	if opts.Recursive {
+		return c.backupChildNamespaces(opts, sink, "")
 	}
+	return nil
+}
 
+// backupNamespace backs up the contents of the client's current namespace
+func (c *Client) backupNamespace(opts BackupOptions, sink BackupSink) error {
 	// Backup secret engines
 	fmt.Println("\nBacking up secret engines...")
-	if err := c.backupSecretEngines(backup, engines); err != nil {
-		return nil, fmt.Errorf("failed to backup secret engines: %w", err)
+	if err := c.backupSecretEngines(sink, opts.Engines, opts.Concurrency); err != nil {
+		return fmt.Errorf("failed to backup secret engines: %w", err)
 	}
 
 	// Backup policies
-	fmt.Println("\nBacking up policies...")
-	if err := c.backupPolicies(backup); err != nil {
-		return nil, fmt.Errorf("failed to backup policies: %w", err)
+	if !opts.SkipPolicies {
+		fmt.Println("\nBacking up policies...")
+		if err := c.backupPolicies(sink); err != nil {
+			return fmt.Errorf("failed to backup policies: %w", err)
+		}
 	}
 
 	// Backup auth methods
-	fmt.Println("\nBacking up auth methods...")
-	if err := c.backupAuthMethods(backup); err != nil {
-		return nil, fmt.Errorf("failed to backup auth methods: %w", err)
+	if !opts.SkipAuth {
+		fmt.Println("\nBacking up auth methods...")
+		if err := c.backupAuthMethods(sink, opts); err != nil {
+			return fmt.Errorf("failed to backup auth methods: %w", err)
+		}
+	}
+
+	// Backup identity entities and groups
+	if !opts.SkipIdentity {
+		fmt.Println("\nBacking up identity...")
+		if err := c.backupIdentity(sink); err != nil {
+			return fmt.Errorf("failed to backup identity: %w", err)
+		}
 	}
 
-	return backup, nil
+	return nil
 }
 
-func (c *Client) backupSecretEngines(backup *BackupData, filterEngines []string) error {
+func (c *Client) backupSecretEngines(sink BackupSink, filterEngines []string, workers int) error {
 	mounts, err := c.client.Sys().ListMounts()
 	if err != nil {
 		return err
 	}
 
-	for path, mount := range mounts {
+	// Walk mounts in a stable order so backups are reproducible
+	paths := make([]string, 0, len(mounts))
+	for path := range mounts {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		mount := mounts[path]
+
 		// Skip system mounts
 		if strings.HasPrefix(path, "sys/") || strings.HasPrefix(path, "identity/") || strings.HasPrefix(path, "cubbyhole/") {
 			continue
@@ -86,186 +136,277 @@ func (c *Client) backupSecretEngines(backup *BackupData, filterEngines []string)
 			Options:     convertStringMapToInterface(mount.Options),
 		}
 
-		// Backup secrets based on engine type
-		if mount.Type == "kv" || mount.Type == "generic" {
-			version := 1
-			if mount.Options != nil && mount.Options["version"] == "2" {
-				version = 2
+		handler := engineHandler(mount.Type)
+		if handler != nil {
+			if err := handler.Backup(c, &engineBackup); err != nil {
+				fmt.Printf("    Warning: %s: %v\n", path, err)
 			}
-			
-			if version == 2 {
-				secrets, err := c.backupKVv2Secrets(path)
-				if err != nil {
-					fmt.Printf("    Warning: failed to backup secrets from %s: %v\n", path, err)
-				} else {
-					engineBackup.Secrets = secrets
-					fmt.Printf("    Backed up %d secrets\n", len(secrets))
-				}
-			} else {
-				secrets, err := c.backupKVv1Secrets(path)
-				if err != nil {
-					fmt.Printf("    Warning: failed to backup secrets from %s: %v\n", path, err)
-				} else {
-					engineBackup.Secrets = secrets
-					fmt.Printf("    Backed up %d secrets\n", len(secrets))
-				}
+		}
+
+		if err := sink.WriteEngine(engineBackup); err != nil {
+			return err
+		}
+
+		emit := func(secret SecretBackup) error {
+			return sink.WriteSecret(path, secret)
+		}
+
+		// Backup secrets if the engine type streams them
+		if secrets, ok := handler.(SecretHandler); ok {
+			count, err := secrets.BackupSecrets(c, engineBackup, workers, emit)
+			if err != nil {
+				return fmt.Errorf("failed to backup secrets from %s: %w", path, err)
 			}
+			fmt.Printf("    Backed up %d secrets\n", count)
 		}
 
-		backup.SecretEngines = append(backup.SecretEngines, engineBackup)
+		if err := sink.EndEngine(path); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (c *Client) backupKVv2Secrets(mountPath string) ([]SecretBackup, error) {
-	var secrets []SecretBackup
-	
-	paths, err := c.listAllPaths(mountPath, "metadata/")
+func (c *Client) backupKVv2Secrets(mountPath string, workers int, emit func(SecretBackup) error) (int, error) {
+	count := 0
+
+	paths, listErrs := c.listAllPaths(mountPath, "metadata/", workers)
+	c.reportFailures("directories could not be listed", listErrs)
+
+	// Secrets are fetched concurrently and emitted in listing order
+	pool := newOrderedPool(workers, func(secret *SecretBackup, err error) error {
+		if secret == nil {
+			return nil
+		}
+		if err := emit(*secret); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+
+	for _, path := range paths {
+		path := path
+		err := pool.Submit(func(log *taskLog) (*SecretBackup, error) {
+			return c.backupKVv2Secret(mountPath, path, log)
+		})
+		if err != nil {
+			return count, err
+		}
+	}
+
+	failures, err := pool.Wait()
 	if err != nil {
-		return nil, err
+		return count, err
 	}
-	
-	if len(paths) == 0 {
-		return secrets, nil
+	c.reportFailures("secrets were not fully backed up", failures)
+	return count, nil
+}
+
+// backupKVv2Secret reads the metadata and every version of one secret. It
+// returns nil if the secret has no versions left to back up.
+func (c *Client) backupKVv2Secret(mountPath, path string, log *taskLog) (*SecretBackup, error) {
+	// Get metadata
+	metadataPath := mountPath + "metadata/" + path
+	metadataResp, err := c.client.Logical().Read(metadataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata of %s: %w", path, err)
+	}
+	if metadataResp == nil {
+		return nil, nil
 	}
 
-	for _, path := range paths {
-		// Get metadata
-		metadataPath := mountPath + "metadata/" + path
-		metadataResp, err := c.client.Logical().Read(metadataPath)
-		if err != nil || metadataResp == nil {
-			continue
-		}
+	secretBackup := SecretBackup{
+		Path: path,
+	}
 
-		secretBackup := SecretBackup{
-			Path: path,
-		}
+	// Parse metadata
+	if metadataResp.Data != nil {
+		secretBackup.Metadata = parseMetadata(metadataResp.Data)
+	}
 
-		// Parse metadata
-		if metadataResp.Data != nil {
-			secretBackup.Metadata = parseMetadata(metadataResp.Data)
-		}
+	// Get all versions
+	versions := secretBackup.Metadata.CurrentVersion
+	if versions == 0 {
+		return nil, nil
+	}
 
-		// Get all versions
-		versions := secretBackup.Metadata.CurrentVersion
-		if versions == 0 {
+	// The metadata also lists deletion_time/destroyed for versions whose
+	// data can no longer be read
+	versionMetadata, _ := metadataResp.Data["versions"].(map[string]interface{})
+	dataPath := fmt.Sprintf("%sdata/%s", mountPath, path)
+
+	var failed []int
+	for v := 1; v <= versions; v++ {
+		version := SecretVersion{Version: v}
+		metadata, known := versionMetadata[strconv.Itoa(v)].(map[string]interface{})
+
+		// Use ReadWithData to pass version as a query parameter
+		versionResp, err := c.client.Logical().ReadWithData(dataPath, map[string][]string{
+			"version": {fmt.Sprintf("%d", v)},
+		})
+		if err != nil {
+			log.Printf("    Warning: failed to read %s version %d: %v\n", path, v, err)
+			failed = append(failed, v)
 			continue
 		}
-		
-		for v := 1; v <= versions; v++ {
-			dataPath := fmt.Sprintf("%sdata/%s", mountPath, path)
-			
-			// Use ReadWithData to pass version as a query parameter
-			versionResp, err := c.client.Logical().ReadWithData(dataPath, map[string][]string{
-				"version": {fmt.Sprintf("%d", v)},
-			})
-			if err != nil || versionResp == nil {
-				continue
-			}
 
-			if versionResp.Data != nil && versionResp.Data["data"] != nil {
-				version := SecretVersion{
-					Version: v,
-					Data:    versionResp.Data["data"].(map[string]interface{}),
-				}
-
-				if metadata, ok := versionResp.Data["metadata"].(map[string]interface{}); ok {
-					if ct, ok := metadata["created_time"].(string); ok {
-						version.CreatedTime, _ = time.Parse(time.RFC3339, ct)
-					}
-					if dt, ok := metadata["deletion_time"].(string); ok {
-						version.DeletionTime = dt
-					}
-					if destroyed, ok := metadata["destroyed"].(bool); ok {
-						version.Destroyed = destroyed
-					}
-				}
-
-				secretBackup.Versions = append(secretBackup.Versions, version)
+		if versionResp != nil && versionResp.Data != nil {
+			if data, ok := versionResp.Data["data"].(map[string]interface{}); ok {
+				version.Data = data
+			}
+			if m, ok := versionResp.Data["metadata"].(map[string]interface{}); ok {
+				metadata, known = m, true
 			}
 		}
 
-		if len(secretBackup.Versions) > 0 {
-			secrets = append(secrets, secretBackup)
+		// Versions pruned by max_versions are gone entirely
+		if !known && version.Data == nil {
+			continue
+		}
+
+		if ct, ok := metadata["created_time"].(string); ok {
+			version.CreatedTime, _ = time.Parse(time.RFC3339, ct)
+		}
+		if dt, ok := metadata["deletion_time"].(string); ok {
+			version.DeletionTime = dt
 		}
+		if destroyed, ok := metadata["destroyed"].(bool); ok {
+			version.Destroyed = destroyed
+		}
+
+		secretBackup.Versions = append(secretBackup.Versions, version)
 	}
 
-	return secrets, nil
+	if len(secretBackup.Versions) == 0 {
+		return nil, nil
+	}
+	if len(failed) > 0 {
+		return &secretBackup, fmt.Errorf("%s: versions %v could not be read", path, failed)
+	}
+	return &secretBackup, nil
 }
 
-func (c *Client) backupKVv1Secrets(mountPath string) ([]SecretBackup, error) {
-	var secrets []SecretBackup
-	
-	paths, err := c.listAllPaths(mountPath, "")
-	if err != nil {
-		return nil, err
-	}
+func (c *Client) backupKVv1Secrets(mountPath string, workers int, emit func(SecretBackup) error) (int, error) {
+	count := 0
 
-	for _, path := range paths {
-		secretPath := mountPath + path
-		resp, err := c.client.Logical().Read(secretPath)
-		if err != nil || resp == nil || resp.Data == nil {
-			continue
+	paths, listErrs := c.listAllPaths(mountPath, "", workers)
+	c.reportFailures("directories could not be listed", listErrs)
+
+	pool := newOrderedPool(workers, func(secret *SecretBackup, err error) error {
+		if secret == nil {
+			return nil
 		}
+		if err := emit(*secret); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+
+	for _, path := range paths {
+		path := path
+		err := pool.Submit(func(log *taskLog) (*SecretBackup, error) {
+			secretPath := mountPath + path
+			resp, err := c.client.Logical().Read(secretPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			if resp == nil || resp.Data == nil {
+				return nil, nil
+			}
 
-		secretBackup := SecretBackup{
-			Path: path,
-			Versions: []SecretVersion{
-				{
-					Version:     1,
-					Data:        resp.Data,
-					CreatedTime: time.Now(),
+			return &SecretBackup{
+				Path: path,
+				Versions: []SecretVersion{
+					{
+						Version:     1,
+						Data:        resp.Data,
+						CreatedTime: time.Now(),
+					},
+				},
+				Metadata: SecretMetadata{
+					CurrentVersion: 1,
+					MaxVersions:    1,
 				},
-			},
-			Metadata: SecretMetadata{
-				CurrentVersion: 1,
-				MaxVersions:    1,
-			},
+			}, nil
+		})
+		if err != nil {
+			return count, err
 		}
+	}
 
-		secrets = append(secrets, secretBackup)
+	failures, err := pool.Wait()
+	if err != nil {
+		return count, err
 	}
+	c.reportFailures("secrets were not backed up", failures)
+	return count, nil
+}
 
-	return secrets, nil
+// listAllPaths lists every secret under prefix, with up to workers list
+// requests in flight. Directories that could not be listed are returned as
+// errors and their secrets are missing from the result.
+func (c *Client) listAllPaths(mountPath, prefix string, workers int) ([]string, []error) {
+	if workers < 1 {
+		workers = 1
+	}
+	return c.walkPaths(mountPath, prefix, make(chan struct{}, workers))
 }
 
-func (c *Client) listAllPaths(mountPath, prefix string) ([]string, error) {
-	var allPaths []string
-	
-	listPath := mountPath + prefix
-	resp, err := c.client.Logical().List(listPath)
-	if err != nil || resp == nil {
-		return allPaths, nil
+// walkPaths lists a KV tree, listing sibling directories concurrently while
+// keeping Vault's key order. At most cap(sem) list requests run at once.
+func (c *Client) walkPaths(mountPath, prefix string, sem chan struct{}) ([]string, []error) {
+	sem <- struct{}{}
+	resp, err := c.client.Logical().List(mountPath + prefix)
+	<-sem
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to list %s%s: %w", mountPath, prefix, err)}
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, nil
 	}
 
-	if keys, ok := resp.Data["keys"].([]interface{}); ok {
-		for _, key := range keys {
-			keyStr := key.(string)
-			
-			if strings.HasSuffix(keyStr, "/") {
-				// It's a directory, recurse
-				subPaths, err := c.listAllPaths(mountPath, prefix+keyStr)
-				if err == nil {
-					for _, subPath := range subPaths {
-						allPaths = append(allPaths, keyStr+subPath)
-					}
-				}
-			} else {
-				allPaths = append(allPaths, keyStr)
-			}
+	keys := toStringSlice(resp.Data["keys"])
+	paths := make([][]string, len(keys))
+	errs := make([][]error, len(keys))
+
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		if !strings.HasSuffix(key, "/") {
+			paths[i] = []string{key}
+			continue
 		}
+
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			subPaths, subErrs := c.walkPaths(mountPath, prefix+key, sem)
+			for _, subPath := range subPaths {
+				paths[i] = append(paths[i], key+subPath)
+			}
+			errs[i] = subErrs
+		}(i, key)
 	}
+	wg.Wait()
 
-	return allPaths, nil
+	var allPaths []string
+	var allErrs []error
+	for i := range keys {
+		allPaths = append(allPaths, paths[i]...)
+		allErrs = append(allErrs, errs[i]...)
+	}
+	return allPaths, allErrs
 }
 
-func (c *Client) backupPolicies(backup *BackupData) error {
+func (c *Client) backupPolicies(sink BackupSink) error {
 	policies, err := c.client.Sys().ListPolicies()
 	if err != nil {
 		return err
 	}
 
+	count := 0
 	for _, policyName := range policies {
 		// Skip default policies
 		if policyName == "root" || policyName == "default" {
@@ -278,23 +419,36 @@ func (c *Client) backupPolicies(backup *BackupData) error {
 			continue
 		}
 
-		backup.Policies = append(backup.Policies, PolicyBackup{
+		if err := sink.WritePolicy(PolicyBackup{
 			Name:   policyName,
 			Policy: policy,
-		})
+		}); err != nil {
+			return err
+		}
+		count++
 	}
 
-	fmt.Printf("  Backed up %d policies\n", len(backup.Policies))
+	fmt.Printf("  Backed up %d policies\n", count)
 	return nil
 }
 
-func (c *Client) backupAuthMethods(backup *BackupData) error {
+func (c *Client) backupAuthMethods(sink BackupSink, opts BackupOptions) error {
 	auths, err := c.client.Sys().ListAuth()
 	if err != nil {
 		return err
 	}
 
-	for path, auth := range auths {
+	// Walk auth methods in a stable order, like the engines
+	paths := make([]string, 0, len(auths))
+	for path := range auths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	count := 0
+	for _, path := range paths {
+		auth := auths[path]
+
 		// Skip token auth (always present)
 		if path == "token/" {
 			continue
@@ -310,29 +464,26 @@ func (c *Client) backupAuthMethods(backup *BackupData) error {
 			Options:     convertStringMapToInterface(auth.Options),
 		}
 
-		// Backup roles and users based on auth type
-		switch auth.Type {
-		case "userpass":
-			users, err := c.backupUserpassUsers(path)
-			if err == nil {
-				authBackup.Users = users
-			}
-		case "approle":
-			roles, err := c.backupAppRoles(path)
-			if err == nil {
-				authBackup.Roles = roles
+		// Backup roles and users if the auth type has a handler
+		if handler := authHandler(auth.Type); handler != nil {
+			if err := handler.Backup(c, &authBackup); err != nil {
+				fmt.Printf("    Warning: %s: %v\n", path, err)
 			}
-		case "ldap":
-			users, err := c.backupLDAPUsers(path)
-			if err == nil {
-				authBackup.Users = users
+		}
+
+		if opts.UserpassHashes && auth.Type == "userpass" {
+			if err := c.backupUserpassHashes(auth.UUID, &authBackup); err != nil {
+				fmt.Printf("    Warning: %s: password hashes not backed up: %v\n", path, err)
 			}
 		}
 
-		backup.AuthMethods = append(backup.AuthMethods, authBackup)
+		if err := sink.WriteAuthMethod(authBackup); err != nil {
+			return err
+		}
+		count++
 	}
 
-	fmt.Printf("  Backed up %d auth methods\n", len(backup.AuthMethods))
+	fmt.Printf("  Backed up %d auth methods\n", count)
 	return nil
 }
 
@@ -414,6 +565,32 @@ func (c *Client) backupLDAPUsers(authPath string) ([]UserBackup, error) {
 	return users, nil
 }
 
+// backupLDAPGroups reads the policy mappings of LDAP groups
+func (c *Client) backupLDAPGroups(authPath string) ([]RoleBackup, error) {
+	listPath := "auth/" + strings.TrimSuffix(authPath, "/") + "/groups"
+	return c.readEntries(listPath, listPath+"/", nil)
+}
+
+// readEntries lists listPath and reads each key from readPrefix+key,
+// dropping the given fields
+func (c *Client) readEntries(listPath, readPrefix string, drop []string) ([]RoleBackup, error) {
+	names, err := c.listKeys(listPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []RoleBackup
+	for _, name := range names {
+		resp, err := c.client.Logical().Read(readPrefix + name)
+		if err != nil || resp == nil || resp.Data == nil {
+			fmt.Printf("    Warning: failed to read %s%s: %v\n", readPrefix, name, err)
+			continue
+		}
+		entries = append(entries, RoleBackup{Name: name, Data: withoutFields(resp.Data, drop)})
+	}
+	return entries, nil
+}
+
 func parseMetadata(data map[string]interface{}) SecretMetadata {
 	metadata := SecretMetadata{}
 
@@ -493,6 +670,11 @@ func convertToMap(v interface{}) map[string]interface{} {
 		for k, v := range val {
 			result[k] = v
 		}
+	default:
+		// Structs such as api.MountConfigOutput are converted through JSON
+		if data, err := json.Marshal(val); err == nil {
+			json.Unmarshal(data, &result)
+		}
 	}
 	return result
 }
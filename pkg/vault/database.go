@@ -0,0 +1,245 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func init() {
+	RegisterEngineHandler("database", databaseHandler{})
+}
+
+// DatabasePasswordPlaceholder is written as the password of connections
+// that have no override, since Vault never returns connection passwords
+const DatabasePasswordPlaceholder = "vault-migrator-placeholder"
+
+// DatabaseBackup holds the connections and roles of a database mount.
+// Connection data is what Vault returns, so it never contains the password.
+type DatabaseBackup struct {
+	Connections []RoleBackup `json:"connections,omitempty"`
+	Roles       []RoleBackup `json:"roles,omitempty"`
+	StaticRoles []RoleBackup `json:"static_roles,omitempty"`
+}
+
+// DatabaseOverrides replaces connection fields on restore, keyed by mount and
+// connection name, e.g. "database/postgres-prod" => {"password": "..."}
+type DatabaseOverrides map[string]map[string]interface{}
+
+// staticRoleStateFields describe the rotation state in the source and are
+// not written back
+var staticRoleStateFields = []string{"last_vault_rotation", "password_last_set", "ttl"}
+
+func LoadDatabaseOverrides(path string) (DatabaseOverrides, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database overrides: %w", err)
+	}
+
+	var overrides DatabaseOverrides
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse database overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// connection returns the overrides of a connection, looked up by its source
+// mount and by the mount it is restored to
+func (o DatabaseOverrides) connection(sourceMount, targetMount, name string) map[string]interface{} {
+	if fields, ok := o[strings.TrimSuffix(targetMount, "/")+"/"+name]; ok {
+		return fields
+	}
+	return o[strings.TrimSuffix(sourceMount, "/")+"/"+name]
+}
+
+type databaseHandler struct{}
+
+func (databaseHandler) Backup(c *Client, engine *SecretEngineBackup) error {
+	connections, err := c.readEntries(engine.Path+"config", engine.Path+"config/", nil)
+	if err != nil {
+		return fmt.Errorf("failed to backup database connections: %w", err)
+	}
+	roles, err := c.readEntries(engine.Path+"roles", engine.Path+"roles/", nil)
+	if err != nil {
+		return fmt.Errorf("failed to backup database roles: %w", err)
+	}
+	staticRoles, err := c.readEntries(engine.Path+"static-roles", engine.Path+"static-roles/", staticRoleStateFields)
+	if err != nil {
+		return fmt.Errorf("failed to backup database static roles: %w", err)
+	}
+
+	engine.Database = &DatabaseBackup{
+		Connections: connections,
+		Roles:       roles,
+		StaticRoles: staticRoles,
+	}
+	fmt.Printf("    Backed up %d connections, %d roles and %d static roles (connection passwords are not included)\n",
+		len(connections), len(roles), len(staticRoles))
+	return nil
+}
+
+func (databaseHandler) Restore(c *Client, mountPath string, engine SecretEngineBackup, opts RestoreOptions) error {
+	if engine.Database == nil {
+		return nil
+	}
+	db := engine.Database
+
+	// Entries restored by an interrupted run are skipped, so a resume does
+	// not rotate static role passwords again
+	connections, skipped := 0, 0
+	var placeholders []string
+	for _, conn := range db.Connections {
+		key := journalKey("connection", mountPath+"config/"+conn.Name)
+		if opts.Journal.Done(key) {
+			skipped++
+			continue
+		}
+		data, placeholder := databaseConnectionData(conn.Data, opts.DatabaseOverrides.connection(engine.Path, mountPath, conn.Name))
+		if _, err := c.client.Logical().Write(mountPath+"config/"+conn.Name, data); err != nil {
+			c.reportFailure("    Warning: failed to restore database connection %s: %v\n", conn.Name, err)
+			continue
+		}
+		if err := opts.Journal.Record(key); err != nil {
+			return err
+		}
+		if placeholder {
+			placeholders = append(placeholders, conn.Name)
+		}
+		connections++
+	}
+
+	roles, skippedRoles, err := c.restoreDatabaseEntries(mountPath+"roles/", db.Roles, opts.Journal)
+	if err != nil {
+		return err
+	}
+	skipped += skippedRoles
+
+	// Creating a static role makes Vault rotate the password of its
+	// database user right away
+	staticRoles, skippedRoles, err := c.restoreDatabaseEntries(mountPath+"static-roles/", db.StaticRoles, opts.Journal)
+	if err != nil {
+		return err
+	}
+	skipped += skippedRoles
+
+	fmt.Printf("    Restored %d of %d connections, %d of %d roles and %d of %d static roles\n",
+		connections, len(db.Connections), roles, len(db.Roles), staticRoles, len(db.StaticRoles))
+	if skipped > 0 {
+		fmt.Printf("    Skipped %d entries restored by an earlier run\n", skipped)
+	}
+	for _, name := range placeholders {
+		fmt.Printf("    Warning: connection %s has a placeholder password and was not verified; set it with: vault write %sconfig/%s password=...\n", name, mountPath, name)
+	}
+	if staticRoles > 0 {
+		fmt.Printf("    Warning: the target now rotates the passwords of %d static role users; credentials issued by the source stop working\n", staticRoles)
+	}
+
+	total := len(db.Connections) + len(db.Roles) + len(db.StaticRoles)
+	return failedEntries(total - connections - roles - staticRoles - skipped)
+}
+
+// restoreDatabaseEntries returns how many entries were written and how many
+// were skipped as already journaled
+func (c *Client) restoreDatabaseEntries(basePath string, entries []RoleBackup, journal *Journal) (int, int, error) {
+	restored, skipped := 0, 0
+	for _, entry := range entries {
+		key := journalKey("role", basePath+entry.Name)
+		if journal.Done(key) {
+			skipped++
+			continue
+		}
+		if _, err := c.client.Logical().Write(basePath+entry.Name, entry.Data); err != nil {
+			c.reportFailure("    Warning: failed to restore %s%s: %v\n", basePath, entry.Name, err)
+			continue
+		}
+		if err := journal.Record(key); err != nil {
+			return restored, skipped, err
+		}
+		restored++
+	}
+	return restored, skipped, nil
+}
+
+// databaseConnectionData turns a connection as read from Vault into the
+// fields config/<name> accepts. Without a password override the connection
+// gets the placeholder password and is not verified.
+func databaseConnectionData(data, overrides map[string]interface{}) (map[string]interface{}, bool) {
+	result := make(map[string]interface{})
+	for k, v := range data {
+		if k == "connection_details" {
+			continue
+		}
+		result[k] = v
+	}
+
+	// Plugin settings are returned nested but written as top-level fields
+	if details, ok := data["connection_details"].(map[string]interface{}); ok {
+		for k, v := range details {
+			result[k] = v
+		}
+	}
+
+	for k, v := range overrides {
+		result[k] = v
+	}
+
+	if _, ok := overrides["password"]; ok {
+		return result, false
+	}
+	result["password"] = DatabasePasswordPlaceholder
+	result["verify_connection"] = false
+	return result, true
+}
+
+func (databaseHandler) Diff(report *DiffReport, mountPath string, left, right SecretEngineBackup, opts DiffOptions) {
+	l, r := left.Database, right.Database
+	if l == nil {
+		l = &DatabaseBackup{}
+	}
+	if r == nil {
+		r = &DatabaseBackup{}
+	}
+
+	diffAuthEntries(report, "database connection", mountPath+"config/", rolesToMap(l.Connections), rolesToMap(r.Connections))
+	diffAuthEntries(report, "database role", mountPath+"roles/", rolesToMap(l.Roles), rolesToMap(r.Roles))
+	diffAuthEntries(report, "database static role", mountPath+"static-roles/", rolesToMap(l.StaticRoles), rolesToMap(r.StaticRoles))
+}
+
+func (databaseHandler) Plan(p *PlanContext, mountPath string, engine SecretEngineBackup) {
+	if engine.Database == nil {
+		return
+	}
+
+	for _, conn := range engine.Database.Connections {
+		p.Entry("database connection", mountPath+"config/"+conn.Name, conn.Data, "password would be set")
+	}
+	for _, role := range engine.Database.Roles {
+		p.Entry("database role", mountPath+"roles/"+role.Name, role.Data, "")
+	}
+	for _, role := range engine.Database.StaticRoles {
+		path := mountPath + "static-roles/" + role.Name
+		resp, err := p.Read(path)
+		switch {
+		case err != nil:
+			p.Add("database static role", path, PlanConflict, err.Error())
+		case resp == nil || resp.Data == nil:
+			p.Add("database static role", path, PlanCreate, "password would be rotated")
+		case equalData(withoutFields(resp.Data, staticRoleStateFields), role.Data):
+			p.Add("database static role", path, PlanSkip, "identical")
+		default:
+			p.Add("database static role", path, PlanUpdate, "settings differ")
+		}
+	}
+}
+
+// withoutFields returns a copy of data without the given keys
+func withoutFields(data map[string]interface{}, fields []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		if !contains(fields, k) {
+			result[k] = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDatabaseConnectionData(t *testing.T) {
+	read := map[string]interface{}{
+		"plugin_name":   "postgresql-database-plugin",
+		"allowed_roles": []interface{}{"app"},
+		"connection_details": map[string]interface{}{
+			"connection_url": "postgresql://{{username}}:{{password}}@db:5432/app",
+			"username":       "vault",
+		},
+	}
+
+	tests := []struct {
+		name            string
+		overrides       map[string]interface{}
+		want            map[string]interface{}
+		wantPlaceholder bool
+	}{
+		{
+			name: "placeholder password",
+			want: map[string]interface{}{
+				"plugin_name":       "postgresql-database-plugin",
+				"allowed_roles":     []interface{}{"app"},
+				"connection_url":    "postgresql://{{username}}:{{password}}@db:5432/app",
+				"username":          "vault",
+				"password":          DatabasePasswordPlaceholder,
+				"verify_connection": false,
+			},
+			wantPlaceholder: true,
+		},
+		{
+			name:      "password override",
+			overrides: map[string]interface{}{"password": "secret", "username": "admin"},
+			want: map[string]interface{}{
+				"plugin_name":    "postgresql-database-plugin",
+				"allowed_roles":  []interface{}{"app"},
+				"connection_url": "postgresql://{{username}}:{{password}}@db:5432/app",
+				"username":       "admin",
+				"password":       "secret",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, placeholder := databaseConnectionData(read, tt.overrides)
+			if !reflect.DeepEqual(got, tt.want) || placeholder != tt.wantPlaceholder {
+				t.Errorf("databaseConnectionData = %#v, %v, want %#v, %v", got, placeholder, tt.want, tt.wantPlaceholder)
+			}
+		})
+	}
+
+	if _, ok := read["password"]; ok {
+		t.Error("databaseConnectionData changed the backed-up data")
+	}
+}
+
+func TestDatabaseOverridesConnection(t *testing.T) {
+	overrides := DatabaseOverrides{
+		"database/app":   {"password": "source"},
+		"postgres/app":   {"password": "target"},
+		"database/other": {"password": "other"},
+	}
+
+	tests := []struct {
+		source, target, name string
+		want                 interface{}
+	}{
+		{"database/", "postgres/", "app", "target"},
+		{"database/", "database/", "app", "source"},
+		{"database/", "postgres/", "other", "other"},
+		{"database/", "postgres/", "missing", nil},
+	}
+
+	for _, tt := range tests {
+		if got := overrides.connection(tt.source, tt.target, tt.name)["password"]; got != tt.want {
+			t.Errorf("connection(%q, %q, %q) password = %v, want %v", tt.source, tt.target, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package vault
+
+import (
+	"sort"
+	"strings"
+)
+
+type DiffChange string
+
+const (
+	DiffAdded   DiffChange = "added"
+	DiffRemoved DiffChange = "removed"
+	DiffChanged DiffChange = "changed"
+)
+
+const maskedValue = "********"
+
+type DiffOptions struct {
+	Engines    []string
+	ShowValues bool
+}
+
+type FieldDiff struct {
+	Field string      `json:"field"`
+	Left  interface{} `json:"left,omitempty"`
+	Right interface{} `json:"right,omitempty"`
+}
+
+type DiffEntry struct {
+	Namespace string      `json:"namespace,omitempty"`
+	Kind      string      `json:"kind"`
+	Path      string      `json:"path"`
+	Change    DiffChange  `json:"change"`
+	Fields    []FieldDiff `json:"fields,omitempty"`
+}
+
+type DiffReport struct {
+	Entries []DiffEntry `json:"entries"`
+}
+
+func (d *DiffReport) Add(kind, path string, change DiffChange, fields []FieldDiff) {
+	d.Entries = append(d.Entries, DiffEntry{Kind: kind, Path: path, Change: change, Fields: fields})
+}
+
+// Diff compares two backups. Entries present only in right are "added",
+// entries present only in left are "removed".
+func Diff(left, right *BackupData, opts DiffOptions) *DiffReport {
+	report := &DiffReport{}
+	diffNamespace(report, "", left, right, opts)
+	return report
+}
+
+// diffNamespace compares the contents of one namespace, then its children.
+// Child namespaces are matched by path.
+func diffNamespace(report *DiffReport, namespace string, left, right *BackupData, opts DiffOptions) {
+	contents := &DiffReport{}
+	diffEngines(contents, left, right, opts)
+	diffPolicies(contents, left, right)
+	diffAuthMethods(contents, left, right)
+	diffIdentity(contents, left, right)
+	for _, entry := range contents.Entries {
+		entry.Namespace = namespace
+		report.Entries = append(report.Entries, entry)
+	}
+
+	leftChildren := namespacesByPath(left)
+	rightChildren := namespacesByPath(right)
+	for _, path := range unionKeys(leftChildren, rightChildren) {
+		l, inLeft := leftChildren[path]
+		r, inRight := rightChildren[path]
+		switch {
+		case !inLeft:
+			report.Add("namespace", path, DiffAdded, nil)
+		case !inRight:
+			report.Add("namespace", path, DiffRemoved, nil)
+		default:
+			diffNamespace(report, path, l, r, opts)
+		}
+	}
+}
+
+func namespacesByPath(backup *BackupData) map[string]*BackupData {
+	result := make(map[string]*BackupData)
+	for i := range backup.Namespaces {
+		child := &backup.Namespaces[i]
+		if child.Namespace != nil {
+			result[child.Namespace.Path] = child
+		}
+	}
+	return result
+}
+
+func diffEngines(report *DiffReport, left, right *BackupData, opts DiffOptions) {
+	leftEngines := make(map[string]SecretEngineBackup)
+	for _, engine := range left.SecretEngines {
+		leftEngines[engine.Path] = engine
+	}
+	rightEngines := make(map[string]SecretEngineBackup)
+	for _, engine := range right.SecretEngines {
+		rightEngines[engine.Path] = engine
+	}
+
+	for _, path := range unionKeys(leftEngines, rightEngines) {
+		if len(opts.Engines) > 0 && !contains(opts.Engines, strings.TrimSuffix(path, "/")) {
+			continue
+		}
+
+		l, inLeft := leftEngines[path]
+		r, inRight := rightEngines[path]
+		switch {
+		case !inLeft:
+			report.Add("mount", path, DiffAdded, []FieldDiff{{Field: "type", Right: r.Type}})
+		case !inRight:
+			report.Add("mount", path, DiffRemoved, []FieldDiff{{Field: "type", Left: l.Type}})
+		default:
+			var fields []FieldDiff
+			if l.Type != r.Type {
+				fields = append(fields, FieldDiff{Field: "type", Left: l.Type, Right: r.Type})
+			}
+			fields = append(fields, diffMaps("options.", l.Options, r.Options, true)...)
+			fields = append(fields, diffMaps("config.", l.Config, r.Config, true)...)
+			if len(fields) > 0 {
+				report.Add("mount", path, DiffChanged, fields)
+			}
+		}
+
+		mountType := l.Type
+		if !inLeft {
+			mountType = r.Type
+		}
+		if handler := engineHandler(mountType); handler != nil {
+			handler.Diff(report, path, l, r, opts)
+		}
+	}
+}
+
+func diffTransitKeys(report *DiffReport, mountPath string, left, right []TransitKeyBackup) {
+	leftKeys := make(map[string]map[string]interface{})
+	for _, key := range left {
+		leftKeys[key.Name] = transitKeySummary(key)
+	}
+	rightKeys := make(map[string]map[string]interface{})
+	for _, key := range right {
+		rightKeys[key.Name] = transitKeySummary(key)
+	}
+	diffAuthEntries(report, "transit key", mountPath+"keys/", leftKeys, rightKeys)
+}
+
+func transitKeySummary(key TransitKeyBackup) map[string]interface{} {
+	summary := map[string]interface{}{
+		"type":         key.Type,
+		"key_material": key.Backup != "",
+	}
+	for k, v := range key.Config {
+		summary[k] = v
+	}
+	return summary
+}
+
+func diffSecrets(report *DiffReport, mountPath string, left, right []SecretBackup, opts DiffOptions) {
+	leftSecrets := make(map[string]SecretBackup)
+	for _, secret := range left {
+		leftSecrets[secret.Path] = secret
+	}
+	rightSecrets := make(map[string]SecretBackup)
+	for _, secret := range right {
+		rightSecrets[secret.Path] = secret
+	}
+
+	for _, path := range unionKeys(leftSecrets, rightSecrets) {
+		l, inLeft := leftSecrets[path]
+		r, inRight := rightSecrets[path]
+		switch {
+		case !inLeft:
+			report.Add("secret", mountPath+path, DiffAdded, nil)
+		case !inRight:
+			report.Add("secret", mountPath+path, DiffRemoved, nil)
+		default:
+			var fields []FieldDiff
+			if l.Metadata.CurrentVersion != r.Metadata.CurrentVersion {
+				fields = append(fields, FieldDiff{Field: "versions", Left: l.Metadata.CurrentVersion, Right: r.Metadata.CurrentVersion})
+			}
+
+			var leftData, rightData map[string]interface{}
+			if v := latestVersion(l); v != nil {
+				leftData = v.Data
+			}
+			if v := latestVersion(r); v != nil {
+				rightData = v.Data
+			}
+			fields = append(fields, diffMaps("data.", leftData, rightData, opts.ShowValues)...)
+
+			if len(fields) > 0 {
+				report.Add("secret", mountPath+path, DiffChanged, fields)
+			}
+		}
+	}
+}
+
+func diffPolicies(report *DiffReport, left, right *BackupData) {
+	leftPolicies := make(map[string]string)
+	for _, policy := range left.Policies {
+		leftPolicies[policy.Name] = policy.Policy
+	}
+	rightPolicies := make(map[string]string)
+	for _, policy := range right.Policies {
+		rightPolicies[policy.Name] = policy.Policy
+	}
+
+	for _, name := range unionKeys(leftPolicies, rightPolicies) {
+		l, inLeft := leftPolicies[name]
+		r, inRight := rightPolicies[name]
+		switch {
+		case !inLeft:
+			report.Add("policy", name, DiffAdded, nil)
+		case !inRight:
+			report.Add("policy", name, DiffRemoved, nil)
+		case strings.TrimSpace(l) != strings.TrimSpace(r):
+			report.Add("policy", name, DiffChanged, []FieldDiff{{Field: "policy", Left: l, Right: r}})
+		}
+	}
+}
+
+func diffAuthMethods(report *DiffReport, left, right *BackupData) {
+	leftAuths := make(map[string]AuthMethodBackup)
+	for _, auth := range left.AuthMethods {
+		leftAuths[auth.Path] = auth
+	}
+	rightAuths := make(map[string]AuthMethodBackup)
+	for _, auth := range right.AuthMethods {
+		rightAuths[auth.Path] = auth
+	}
+
+	for _, path := range unionKeys(leftAuths, rightAuths) {
+		l, inLeft := leftAuths[path]
+		r, inRight := rightAuths[path]
+		switch {
+		case !inLeft:
+			report.Add("auth", path, DiffAdded, []FieldDiff{{Field: "type", Right: r.Type}})
+		case !inRight:
+			report.Add("auth", path, DiffRemoved, []FieldDiff{{Field: "type", Left: l.Type}})
+		default:
+			var fields []FieldDiff
+			if l.Type != r.Type {
+				fields = append(fields, FieldDiff{Field: "type", Left: l.Type, Right: r.Type})
+			}
+			fields = append(fields, diffMaps("config.", l.Config, r.Config, true)...)
+			if len(fields) > 0 {
+				report.Add("auth", path, DiffChanged, fields)
+			}
+		}
+
+		authType := l.Type
+		if !inLeft {
+			authType = r.Type
+		}
+		if handler := authHandler(authType); handler != nil {
+			handler.Diff(report, path, l, r)
+		}
+	}
+}
+
+func diffAuthEntries(report *DiffReport, kind, authPath string, left, right map[string]map[string]interface{}) {
+	for _, name := range unionKeys(left, right) {
+		l, inLeft := left[name]
+		r, inRight := right[name]
+		switch {
+		case !inLeft:
+			report.Add(kind, authPath+name, DiffAdded, nil)
+		case !inRight:
+			report.Add(kind, authPath+name, DiffRemoved, nil)
+		default:
+			if fields := diffMaps("", l, r, true); len(fields) > 0 {
+				report.Add(kind, authPath+name, DiffChanged, fields)
+			}
+		}
+	}
+}
+
+// diffIdentity compares entities and groups by name, since IDs always differ
+// between clusters. Group members are resolved to names on each side.
+func diffIdentity(report *DiffReport, left, right *BackupData) {
+	leftEntities := make(map[string]map[string]interface{})
+	for _, entity := range left.Entities {
+		leftEntities[entity.Name] = entitySummary(entity)
+	}
+	rightEntities := make(map[string]map[string]interface{})
+	for _, entity := range right.Entities {
+		rightEntities[entity.Name] = entitySummary(entity)
+	}
+	diffAuthEntries(report, "entity", "", leftEntities, rightEntities)
+
+	leftGroups := make(map[string]map[string]interface{})
+	for _, group := range left.Groups {
+		leftGroups[group.Name] = groupSummary(group, left)
+	}
+	rightGroups := make(map[string]map[string]interface{})
+	for _, group := range right.Groups {
+		rightGroups[group.Name] = groupSummary(group, right)
+	}
+	diffAuthEntries(report, "group", "", leftGroups, rightGroups)
+}
+
+func entitySummary(entity EntityBackup) map[string]interface{} {
+	var aliases []string
+	for _, alias := range entity.Aliases {
+		aliases = append(aliases, alias.MountPath+alias.Name)
+	}
+	return map[string]interface{}{
+		"policies": sortedStrings(entity.Policies),
+		"disabled": entity.Disabled,
+		"aliases":  sortedStrings(aliases),
+	}
+}
+
+func groupSummary(group GroupBackup, backup *BackupData) map[string]interface{} {
+	entityNames := make(map[string]string)
+	for _, entity := range backup.Entities {
+		entityNames[entity.ID] = entity.Name
+	}
+	groupNames := make(map[string]string)
+	for _, g := range backup.Groups {
+		groupNames[g.ID] = g.Name
+	}
+
+	var members []string
+	for _, id := range group.MemberEntityIDs {
+		members = append(members, "entity:"+entityNames[id])
+	}
+	for _, id := range group.MemberGroupIDs {
+		members = append(members, "group:"+groupNames[id])
+	}
+
+	summary := map[string]interface{}{
+		"type":     group.Type,
+		"policies": sortedStrings(group.Policies),
+		"members":  sortedStrings(members),
+	}
+	if group.Alias != nil {
+		summary["alias"] = group.Alias.MountPath + group.Alias.Name
+	}
+	return summary
+}
+
+func sortedStrings(values []string) []string {
+	result := append([]string{}, values...)
+	sort.Strings(result)
+	return result
+}
+
+// diffMaps reports keys whose values differ. Values are masked unless
+// showValues is set.
+func diffMaps(prefix string, left, right map[string]interface{}, showValues bool) []FieldDiff {
+	var fields []FieldDiff
+	for _, key := range unionKeys(left, right) {
+		l, inLeft := left[key]
+		r, inRight := right[key]
+		if inLeft && inRight && equalData(l, r) {
+			continue
+		}
+
+		field := FieldDiff{Field: prefix + key}
+		if inLeft {
+			field.Left = maskValue(l, showValues)
+		}
+		if inRight {
+			field.Right = maskValue(r, showValues)
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func maskValue(v interface{}, showValues bool) interface{} {
+	if showValues {
+		return v
+	}
+	return maskedValue
+}
+
+func usersToMap(users []UserBackup) map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	for _, user := range users {
+		result[user.Name] = user.Data
+	}
+	return result
+}
+
+func rolesToMap(roles []RoleBackup) map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	for _, role := range roles {
+		result[role.Name] = role.Data
+	}
+	return result
+}
+
+func unionKeys[V any](left, right map[string]V) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for k := range left {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	for k := range right {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
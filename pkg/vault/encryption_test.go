@@ -0,0 +1,150 @@
+package vault
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const testPlaintext = `{"version":"1.0"}` + "\n"
+
+func encryptTestData(t *testing.T, opts EncryptionOptions, armored bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var out io.Writer = &buf
+	var armorWriter io.WriteCloser
+	if armored {
+		armorWriter = armor.NewWriter(&buf)
+		out = armorWriter
+	}
+
+	w, err := NewEncryptingWriter(out, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, testPlaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if armorWriter != nil {
+		if err := armorWriter.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func decryptTestData(data []byte, opts DecryptionOptions) (string, error) {
+	r, err := NewDecryptingReader(bytes.NewReader(data), opts)
+	if err != nil {
+		return "", err
+	}
+	plain, err := io.ReadAll(r)
+	return string(plain), err
+}
+
+func writeTestIdentity(t *testing.T, identity *age.X25519Identity) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(path, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	identityFile := writeTestIdentity(t, identity)
+	otherFile := writeTestIdentity(t, other)
+
+	tests := []struct {
+		name    string
+		encrypt EncryptionOptions
+		armored bool
+		decrypt DecryptionOptions
+		wantErr string
+	}{
+		{name: "passphrase", encrypt: EncryptionOptions{Passphrase: "secret"}, decrypt: DecryptionOptions{Passphrase: "secret"}},
+		{name: "passphrase armored", encrypt: EncryptionOptions{Passphrase: "secret"}, armored: true, decrypt: DecryptionOptions{Passphrase: "secret"}},
+		{name: "wrong passphrase", encrypt: EncryptionOptions{Passphrase: "secret"}, decrypt: DecryptionOptions{Passphrase: "wrong"}, wantErr: "failed to decrypt"},
+		{name: "recipient", encrypt: EncryptionOptions{Recipients: []string{identity.Recipient().String()}}, decrypt: DecryptionOptions{IdentityFiles: []string{identityFile}}},
+		{name: "recipient armored", encrypt: EncryptionOptions{Recipients: []string{identity.Recipient().String()}}, armored: true, decrypt: DecryptionOptions{IdentityFiles: []string{identityFile}}},
+		{name: "one of several identities", encrypt: EncryptionOptions{Recipients: []string{identity.Recipient().String()}}, decrypt: DecryptionOptions{IdentityFiles: []string{otherFile, identityFile}}},
+		{name: "wrong identity", encrypt: EncryptionOptions{Recipients: []string{identity.Recipient().String()}}, decrypt: DecryptionOptions{IdentityFiles: []string{otherFile}}, wantErr: "failed to decrypt"},
+		{name: "no identity", encrypt: EncryptionOptions{Recipients: []string{identity.Recipient().String()}}, wantErr: "a passphrase or identity file is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encryptTestData(t, tt.encrypt, tt.armored)
+			if bytes.Contains(data, []byte("version")) {
+				t.Fatal("encrypted data contains the plaintext")
+			}
+
+			got, err := decryptTestData(data, tt.decrypt)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != testPlaintext {
+				t.Errorf("decrypted %q, want %q", got, testPlaintext)
+			}
+		})
+	}
+}
+
+func TestDecryptingReaderPlaintext(t *testing.T) {
+	got, err := decryptTestData([]byte(testPlaintext), DecryptionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != testPlaintext {
+		t.Errorf("plaintext changed to %q", got)
+	}
+}
+
+func TestEncryptingWriterOptions(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewEncryptingWriter(&buf, EncryptionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, testPlaintext)
+	w.Close()
+	if buf.String() != testPlaintext {
+		t.Errorf("unencrypted output %q", buf.String())
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []EncryptionOptions{
+		{Passphrase: "secret", Recipients: []string{identity.Recipient().String()}},
+		{Recipients: []string{"not-a-recipient"}},
+	} {
+		if _, err := NewEncryptingWriter(&buf, opts); err == nil {
+			t.Errorf("NewEncryptingWriter(%+v): expected an error", opts)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package vault
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// EngineHandler backs up, restores and compares what is stored in one type of
+// secret engine. The mount itself, with its config and options, is handled by
+// the caller for every type.
+type EngineHandler interface {
+	// Backup reads engine-specific state, such as transit keys, into engine
+	Backup(c *Client, engine *SecretEngineBackup) error
+
+	// Restore writes the state read by Backup to the mount at mountPath,
+	// which may differ from engine.Path when mounts are rewritten. Any error
+	// keeps the mount out of the journal, so a resumed run restores it again.
+	Restore(c *Client, mountPath string, engine SecretEngineBackup, opts RestoreOptions) error
+
+	// Diff compares two backups of the mount. Either side may be empty when
+	// the mount only exists on the other.
+	Diff(report *DiffReport, mountPath string, left, right SecretEngineBackup, opts DiffOptions)
+}
+
+// SecretHandler is implemented by engine handlers whose secrets are streamed
+// one at a time instead of being kept on the engine record. Secrets are read
+// and written concurrently, so both methods must be safe for that.
+type SecretHandler interface {
+	BackupSecrets(c *Client, engine SecretEngineBackup, workers int, emit func(SecretBackup) error) (int, error)
+	RestoreSecret(c *Client, mountPath string, engine SecretEngineBackup, secret SecretBackup, opts RestoreOptions, log Logger) error
+}
+
+// AuthHandler backs up, restores and compares the users, roles and other
+// entries of one type of auth method. Enabling and tuning the method is done
+// by the caller.
+type AuthHandler interface {
+	Backup(c *Client, auth *AuthMethodBackup) error
+	Restore(c *Client, auth AuthMethodBackup, opts RestoreOptions) error
+	Diff(report *DiffReport, authPath string, left, right AuthMethodBackup)
+}
+
+// EnginePlanner, SecretPlanner and AuthPlanner are implemented by handlers
+// that can report what a restore would change
+type EnginePlanner interface {
+	Plan(p *PlanContext, mountPath string, engine SecretEngineBackup)
+}
+
+type SecretPlanner interface {
+	PlanSecret(p *PlanContext, mountPath string, engine SecretEngineBackup, secret SecretBackup)
+}
+
+type AuthPlanner interface {
+	Plan(p *PlanContext, auth AuthMethodBackup)
+}
+
+// entriesFailed is returned by an engine handler's Restore when some of its
+// entries could not be written. Each was reported when it failed.
+type entriesFailed int
+
+func (n entriesFailed) Error() string {
+	return fmt.Sprintf("%d entries were not restored", int(n))
+}
+
+func failedEntries(n int) error {
+	if n == 0 {
+		return nil
+	}
+	return entriesFailed(n)
+}
+
+// Logger receives the output of a handler running on a worker, so it can be
+// printed in order
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+var (
+	handlersMu     sync.RWMutex
+	engineHandlers = make(map[string]EngineHandler)
+	authHandlers   = make(map[string]AuthHandler)
+)
+
+// RegisterEngineHandler sets the handler for mounts of mountType, replacing
+// any earlier one. Mounts without a handler are backed up and restored
+// without their contents.
+func RegisterEngineHandler(mountType string, handler EngineHandler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	engineHandlers[mountType] = handler
+}
+
+// RegisterAuthHandler sets the handler for auth methods of authType
+func RegisterAuthHandler(authType string, handler AuthHandler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	authHandlers[authType] = handler
+}
+
+func engineHandler(mountType string) EngineHandler {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	return engineHandlers[mountType]
+}
+
+func authHandler(authType string) AuthHandler {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	return authHandlers[authType]
+}
+
+// API returns the underlying Vault API client for handlers outside this
+// package. It is set to the namespace being backed up or restored.
+func (c *Client) API() *api.Client {
+	return c.client
+}
+
+// PlanContext lets handlers read the target and add items to a plan
+type PlanContext struct {
+	p *planner
+}
+
+// Read reads from the target; in a namespace that does not exist yet it
+// finds nothing
+func (ctx *PlanContext) Read(path string) (*api.Secret, error) {
+	return ctx.p.read(path)
+}
+
+// List lists keys in the target
+func (ctx *PlanContext) List(path string) ([]string, error) {
+	if ctx.p.missing {
+		return nil, nil
+	}
+	return ctx.p.c.listKeys(path)
+}
+
+// Options are the options the restore would run with
+func (ctx *PlanContext) Options() RestoreOptions {
+	return ctx.p.opts
+}
+
+func (ctx *PlanContext) Add(kind, path string, action PlanAction, detail string) {
+	ctx.p.plan.add(kind, path, action, detail)
+}
+
+// Entry plans a user, role or similar entry by comparing data with the
+// target. A non-empty alwaysUpdate marks entries that restore rewrites even
+// when identical.
+func (ctx *PlanContext) Entry(kind, path string, data map[string]interface{}, alwaysUpdate string) {
+	ctx.p.planAuthEntry(kind, path, data, alwaysUpdate)
+}
@@ -0,0 +1,347 @@
+package vault
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (c *Client) backupIdentity(sink BackupSink) error {
+	entities, err := c.listKeys("identity/entity/id")
+	if err != nil {
+		return err
+	}
+
+	entityCount := 0
+	for _, id := range entities {
+		resp, err := c.client.Logical().Read("identity/entity/id/" + id)
+		if err != nil || resp == nil || resp.Data == nil {
+			fmt.Printf("  Warning: failed to read entity %s: %v\n", id, err)
+			continue
+		}
+
+		if err := sink.WriteEntity(parseEntity(resp.Data)); err != nil {
+			return err
+		}
+		entityCount++
+	}
+	fmt.Printf("  Backed up %d entities\n", entityCount)
+
+	groups, err := c.listKeys("identity/group/id")
+	if err != nil {
+		return err
+	}
+
+	groupCount := 0
+	for _, id := range groups {
+		resp, err := c.client.Logical().Read("identity/group/id/" + id)
+		if err != nil || resp == nil || resp.Data == nil {
+			fmt.Printf("  Warning: failed to read group %s: %v\n", id, err)
+			continue
+		}
+
+		if err := sink.WriteGroup(parseGroup(resp.Data)); err != nil {
+			return err
+		}
+		groupCount++
+	}
+	fmt.Printf("  Backed up %d groups\n", groupCount)
+
+	return nil
+}
+
+// listKeys lists a path and returns its keys; a missing path yields no keys
+func (c *Client) listKeys(path string) ([]string, error) {
+	resp, err := c.client.Logical().List(path)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, nil
+	}
+	return toStringSlice(resp.Data["keys"]), nil
+}
+
+func parseEntity(data map[string]interface{}) EntityBackup {
+	entity := EntityBackup{
+		ID:       stringValue(data["id"]),
+		Name:     stringValue(data["name"]),
+		Policies: toStringSlice(data["policies"]),
+		Metadata: toStringMap(data["metadata"]),
+	}
+	if disabled, ok := data["disabled"].(bool); ok {
+		entity.Disabled = disabled
+	}
+
+	if aliases, ok := data["aliases"].([]interface{}); ok {
+		for _, a := range aliases {
+			if alias, ok := a.(map[string]interface{}); ok {
+				entity.Aliases = append(entity.Aliases, parseAlias(alias))
+			}
+		}
+	}
+
+	return entity
+}
+
+func parseGroup(data map[string]interface{}) GroupBackup {
+	group := GroupBackup{
+		ID:              stringValue(data["id"]),
+		Name:            stringValue(data["name"]),
+		Type:            stringValue(data["type"]),
+		Policies:        toStringSlice(data["policies"]),
+		Metadata:        toStringMap(data["metadata"]),
+		MemberEntityIDs: toStringSlice(data["member_entity_ids"]),
+		MemberGroupIDs:  toStringSlice(data["member_group_ids"]),
+	}
+
+	if alias, ok := data["alias"].(map[string]interface{}); ok && len(alias) > 0 {
+		a := parseAlias(alias)
+		group.Alias = &a
+	}
+
+	return group
+}
+
+func parseAlias(data map[string]interface{}) AliasBackup {
+	return AliasBackup{
+		Name:           stringValue(data["name"]),
+		MountAccessor:  stringValue(data["mount_accessor"]),
+		MountPath:      stringValue(data["mount_path"]),
+		MountType:      stringValue(data["mount_type"]),
+		CustomMetadata: toStringMap(data["custom_metadata"]),
+	}
+}
+
+// identityRestore tracks how source identity IDs map to the target cluster
+type identityRestore struct {
+	entityIDs map[string]string
+	groupIDs  map[string]string
+
+	// accessors maps auth mount paths to their accessors in the target
+	accessors map[string]string
+
+	// nested group memberships are applied once every group exists
+	pendingGroups []GroupBackup
+}
+
+func (c *Client) newIdentityRestore() (*identityRestore, error) {
+	auths, err := c.client.Sys().ListAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &identityRestore{
+		entityIDs: make(map[string]string),
+		groupIDs:  make(map[string]string),
+		accessors: make(map[string]string),
+	}
+	for path, auth := range auths {
+		state.accessors[path] = auth.Accessor
+	}
+	return state, nil
+}
+
+// targetAccessor returns the target accessor for the auth mount an alias was
+// created on in the source
+func (s *identityRestore) targetAccessor(alias AliasBackup) (string, error) {
+	path := strings.TrimSuffix(strings.TrimPrefix(alias.MountPath, "auth/"), "/") + "/"
+	if accessor, ok := s.accessors[path]; ok {
+		return accessor, nil
+	}
+	return "", fmt.Errorf("auth mount %s does not exist in the target", path)
+}
+
+func (c *Client) restoreEntity(state *identityRestore, entity EntityBackup) error {
+	entityPath := "identity/entity/name/" + entity.Name
+	_, err := c.client.Logical().Write(entityPath, map[string]interface{}{
+		"policies": entity.Policies,
+		"metadata": entity.Metadata,
+		"disabled": entity.Disabled,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Logical().Read(entityPath)
+	if err != nil || resp == nil || resp.Data == nil {
+		return fmt.Errorf("failed to read back entity %s: %v", entity.Name, err)
+	}
+	targetID := stringValue(resp.Data["id"])
+	state.entityIDs[entity.ID] = targetID
+
+	existing := make(map[string]bool)
+	for _, alias := range parseEntity(resp.Data).Aliases {
+		existing[alias.MountAccessor+"/"+alias.Name] = true
+	}
+
+	for _, alias := range entity.Aliases {
+		accessor, err := state.targetAccessor(alias)
+		if err != nil {
+			fmt.Printf("    Warning: skipping alias %s of entity %s: %v\n", alias.Name, entity.Name, err)
+			continue
+		}
+		if existing[accessor+"/"+alias.Name] {
+			continue
+		}
+
+		aliasData := map[string]interface{}{
+			"name":           alias.Name,
+			"canonical_id":   targetID,
+			"mount_accessor": accessor,
+		}
+		if len(alias.CustomMetadata) > 0 {
+			aliasData["custom_metadata"] = alias.CustomMetadata
+		}
+
+		if _, err := c.client.Logical().Write("identity/entity-alias", aliasData); err != nil {
+			c.reportFailure("    Warning: failed to restore alias %s of entity %s: %v\n", alias.Name, entity.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) restoreGroup(state *identityRestore, group GroupBackup) error {
+	groupPath := "identity/group/name/" + group.Name
+	groupData := map[string]interface{}{
+		"type":     group.Type,
+		"policies": group.Policies,
+		"metadata": group.Metadata,
+	}
+
+	// External groups get their members from the alias, not explicitly
+	if group.Type != "external" {
+		var members []string
+		for _, id := range group.MemberEntityIDs {
+			if targetID, ok := state.entityIDs[id]; ok {
+				members = append(members, targetID)
+			} else {
+				fmt.Printf("    Warning: group %s member entity %s was not restored\n", group.Name, id)
+			}
+		}
+		groupData["member_entity_ids"] = members
+	}
+
+	if _, err := c.client.Logical().Write(groupPath, groupData); err != nil {
+		return err
+	}
+
+	resp, err := c.client.Logical().Read(groupPath)
+	if err != nil || resp == nil || resp.Data == nil {
+		return fmt.Errorf("failed to read back group %s: %v", group.Name, err)
+	}
+	targetID := stringValue(resp.Data["id"])
+	state.groupIDs[group.ID] = targetID
+
+	if group.Alias != nil {
+		current := parseGroup(resp.Data).Alias
+		accessor, err := state.targetAccessor(*group.Alias)
+		switch {
+		case err != nil:
+			fmt.Printf("    Warning: skipping alias of group %s: %v\n", group.Name, err)
+		case current != nil && current.Name == group.Alias.Name && current.MountAccessor == accessor:
+			// already in place
+		default:
+			_, err := c.client.Logical().Write("identity/group-alias", map[string]interface{}{
+				"name":           group.Alias.Name,
+				"mount_accessor": accessor,
+				"canonical_id":   targetID,
+			})
+			if err != nil {
+				c.reportFailure("    Warning: failed to restore alias of group %s: %v\n", group.Name, err)
+			}
+		}
+	}
+
+	if len(group.MemberGroupIDs) > 0 {
+		state.pendingGroups = append(state.pendingGroups, group)
+	}
+
+	return nil
+}
+
+// resolveEntity records the target ID of an entity restored by an earlier run
+func (c *Client) resolveEntity(state *identityRestore, entity EntityBackup) error {
+	resp, err := c.client.Logical().Read("identity/entity/name/" + entity.Name)
+	if err != nil {
+		return fmt.Errorf("failed to look up entity %s: %w", entity.Name, err)
+	}
+	if resp != nil && resp.Data != nil {
+		state.entityIDs[entity.ID] = stringValue(resp.Data["id"])
+	}
+	return nil
+}
+
+// resolveGroup records the target ID of a group restored by an earlier run.
+// Nested memberships are reapplied since they are set after all groups.
+func (c *Client) resolveGroup(state *identityRestore, group GroupBackup) error {
+	resp, err := c.client.Logical().Read("identity/group/name/" + group.Name)
+	if err != nil {
+		return fmt.Errorf("failed to look up group %s: %w", group.Name, err)
+	}
+	if resp != nil && resp.Data != nil {
+		state.groupIDs[group.ID] = stringValue(resp.Data["id"])
+	}
+	if len(group.MemberGroupIDs) > 0 {
+		state.pendingGroups = append(state.pendingGroups, group)
+	}
+	return nil
+}
+
+// restoreGroupMemberships sets member_group_ids once all groups exist
+func (c *Client) restoreGroupMemberships(state *identityRestore) {
+	for _, group := range state.pendingGroups {
+		var members []string
+		for _, id := range group.MemberGroupIDs {
+			if targetID, ok := state.groupIDs[id]; ok {
+				members = append(members, targetID)
+			} else {
+				fmt.Printf("    Warning: group %s member group %s was not restored\n", group.Name, id)
+			}
+		}
+
+		_, err := c.client.Logical().Write("identity/group/name/"+group.Name, map[string]interface{}{
+			"member_group_ids": members,
+		})
+		if err != nil {
+			c.reportFailure("    Warning: failed to set member groups of %s: %v\n", group.Name, err)
+		}
+	}
+	state.pendingGroups = nil
+}
+
+func stringValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+func toStringSlice(v interface{}) []string {
+	var result []string
+	switch val := v.(type) {
+	case []string:
+		return val
+	case []interface{}:
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+func toStringMap(v interface{}) map[string]string {
+	switch val := v.(type) {
+	case map[string]string:
+		return val
+	case map[string]interface{}:
+		result := make(map[string]string)
+		for k, item := range val {
+			result[k] = fmt.Sprintf("%v", item)
+		}
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package vault
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Journal records every engine, secret, policy, user, role, entity and group
+// that a restore has completed, one key per line. Reopening it with resume set
+// lets a failed restore skip the work that already succeeded.
+//
+// Values, such as the version a KV v2 secret started at in the target, are
+// stored as key<TAB>=value lines.
+//
+// A nil *Journal is valid and records nothing.
+type Journal struct {
+	*journalFile
+
+	// scope prefixes keys recorded inside a namespace
+	scope string
+}
+
+type journalFile struct {
+	mu     sync.Mutex
+	file   *os.File
+	done   map[string]bool
+	values map[string]string
+}
+
+func OpenJournal(path string, resume bool) (*Journal, error) {
+	j := &Journal{journalFile: &journalFile{
+		done:   make(map[string]bool),
+		values: make(map[string]string),
+	}}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+
+		f, err := os.Open(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read journal: %w", err)
+		}
+		if err == nil {
+			scanner := bufio.NewScanner(f)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if i := strings.LastIndex(line, "\t="); i >= 0 {
+					j.values[line[:i]] = line[i+2:]
+				} else if line != "" {
+					j.done[line] = true
+				}
+			}
+			f.Close()
+			if err := scanner.Err(); err != nil {
+				return nil, fmt.Errorf("failed to read journal: %w", err)
+			}
+		}
+	}
+
+	f, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	j.file = f
+
+	return j, nil
+}
+
+// Completed returns how many entries were loaded from a previous run
+func (j *Journal) Completed() int {
+	if j == nil {
+		return 0
+	}
+	return len(j.done)
+}
+
+// Scoped returns a view of the journal for work inside a namespace
+func (j *Journal) Scoped(namespace string) *Journal {
+	if j == nil || namespace == "" {
+		return j
+	}
+	return &Journal{journalFile: j.journalFile, scope: namespace + "\t"}
+}
+
+func (j *Journal) Done(key string) bool {
+	if j == nil {
+		return false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done[j.scope+key]
+}
+
+func (j *Journal) Record(key string) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	key = j.scope + key
+	if j.done[key] {
+		return nil
+	}
+	if _, err := j.file.WriteString(key + "\n"); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	j.done[key] = true
+	return nil
+}
+
+// Value returns the value recorded for key by RecordValue
+func (j *Journal) Value(key string) (string, bool) {
+	if j == nil {
+		return "", false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	value, ok := j.values[j.scope+key]
+	return value, ok
+}
+
+// RecordValue records value for key; a later value replaces it
+func (j *Journal) RecordValue(key, value string) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	key = j.scope + key
+	if _, err := j.file.WriteString(key + "\t=" + value + "\n"); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	j.values[key] = value
+	return nil
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
+
+func journalKey(kind, path string) string {
+	return kind + "\t" + path
+}
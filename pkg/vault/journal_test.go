@@ -0,0 +1,109 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestJournal(t *testing.T, path string, resume bool) *Journal {
+	t.Helper()
+	journal, err := OpenJournal(path, resume)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { journal.Close() })
+	return journal
+}
+
+func TestJournalReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.json.journal")
+	engine := journalKey("engine", "secret/")
+	secret := journalKey("secret", "secret/app/db")
+
+	journal := openTestJournal(t, path, false)
+	for _, key := range []string{engine, secret, secret} {
+		if err := journal.Record(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !journal.Done(engine) || journal.Done(journalKey("policy", "app")) {
+		t.Error("Done does not match the recorded keys")
+	}
+	journal.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != engine+"\n"+secret+"\n" {
+		t.Errorf("journal file %q, want each key once", data)
+	}
+
+	resumed := openTestJournal(t, path, true)
+	if resumed.Completed() != 2 || !resumed.Done(engine) || !resumed.Done(secret) {
+		t.Errorf("resumed journal lost entries: %d completed", resumed.Completed())
+	}
+	policy := journalKey("policy", "app")
+	if err := resumed.Record(policy); err != nil {
+		t.Fatal(err)
+	}
+	resumed.Close()
+
+	if again := openTestJournal(t, path, true); again.Completed() != 3 || !again.Done(policy) {
+		t.Errorf("entries recorded after resuming were lost: %d completed", again.Completed())
+	}
+
+	fresh := openTestJournal(t, path, false)
+	if fresh.Completed() != 0 || fresh.Done(engine) {
+		t.Error("journal opened without resume kept the previous run")
+	}
+}
+
+func TestJournalResumeMissingFile(t *testing.T) {
+	journal := openTestJournal(t, filepath.Join(t.TempDir(), "journal"), true)
+	if journal.Completed() != 0 {
+		t.Errorf("new journal has %d entries", journal.Completed())
+	}
+}
+
+func TestNilJournal(t *testing.T) {
+	var journal *Journal
+	if err := journal.Record("key"); err != nil {
+		t.Fatal(err)
+	}
+	if journal.Done("key") || journal.Completed() != 0 {
+		t.Error("nil journal recorded a key")
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJournalScoped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	key := journalKey("secret", "secret/app")
+
+	journal := openTestJournal(t, path, false)
+	teamA := journal.Scoped("team-a/")
+	if err := teamA.Record(key); err != nil {
+		t.Fatal(err)
+	}
+	if !teamA.Done(key) || journal.Done(key) || journal.Scoped("team-b/").Done(key) {
+		t.Error("a key recorded in a namespace leaked into another scope")
+	}
+	if journal.Scoped("") != journal {
+		t.Error("the root scope is not the journal itself")
+	}
+	journal.Close()
+
+	resumed := openTestJournal(t, path, true)
+	if !resumed.Scoped("team-a/").Done(key) || resumed.Done(key) {
+		t.Error("scoped key was not kept across a reopen")
+	}
+
+	var nilJournal *Journal
+	if nilJournal.Scoped("team-a/") != nil {
+		t.Error("scoping a nil journal returned a journal")
+	}
+}
@@ -0,0 +1,90 @@
+package vault
+
+import (
+	"fmt"
+)
+
+func init() {
+	RegisterEngineHandler("kv", kvHandler{})
+	RegisterEngineHandler("generic", kvHandler{})
+}
+
+// kvHandler streams the secrets of KV v1 and v2 mounts, with every version
+// for v2
+type kvHandler struct{}
+
+func (kvHandler) Backup(c *Client, engine *SecretEngineBackup) error {
+	return nil
+}
+
+func (kvHandler) Restore(c *Client, mountPath string, engine SecretEngineBackup, opts RestoreOptions) error {
+	return nil
+}
+
+func (kvHandler) Diff(report *DiffReport, mountPath string, left, right SecretEngineBackup, opts DiffOptions) {
+	diffSecrets(report, mountPath, left.Secrets, right.Secrets, opts)
+}
+
+func (kvHandler) BackupSecrets(c *Client, engine SecretEngineBackup, workers int, emit func(SecretBackup) error) (int, error) {
+	if kvVersion(engine) == 2 {
+		return c.backupKVv2Secrets(engine.Path, workers, emit)
+	}
+	return c.backupKVv1Secrets(engine.Path, workers, emit)
+}
+
+func (kvHandler) RestoreSecret(c *Client, mountPath string, engine SecretEngineBackup, secret SecretBackup, opts RestoreOptions, log Logger) error {
+	if kvVersion(engine) == 2 {
+		return c.restoreKVv2Secret(mountPath, secret, opts.Journal, log)
+	}
+	return c.restoreKVv1Secret(mountPath, secret, log)
+}
+
+func (kvHandler) PlanSecret(p *PlanContext, mountPath string, engine SecretEngineBackup, secret SecretBackup) {
+	path := mountPath + secret.Path
+	latest := latestVersion(secret)
+	if latest == nil {
+		return
+	}
+
+	if kvVersion(engine) == 1 {
+		resp, err := p.Read(path)
+		switch {
+		case err != nil:
+			p.Add("secret", path, PlanConflict, err.Error())
+		case resp == nil || resp.Data == nil:
+			p.Add("secret", path, PlanCreate, "")
+		case equalData(resp.Data, latest.Data):
+			p.Add("secret", path, PlanSkip, "identical")
+		default:
+			p.Add("secret", path, PlanUpdate, "data differs")
+		}
+		return
+	}
+
+	metadata, err := p.Read(mountPath + "metadata/" + secret.Path)
+	if err != nil {
+		p.Add("secret", path, PlanConflict, err.Error())
+		return
+	}
+	if metadata == nil || metadata.Data == nil {
+		p.Add("secret", path, PlanCreate, fmt.Sprintf("%d versions", len(secret.Versions)))
+		return
+	}
+
+	current := parseMetadata(metadata.Data).CurrentVersion
+	resp, err := p.Read(mountPath + "data/" + secret.Path)
+	if err != nil {
+		p.Add("secret", path, PlanConflict, err.Error())
+		return
+	}
+
+	var data interface{}
+	if resp != nil && resp.Data != nil {
+		data = resp.Data["data"]
+	}
+	if current == secret.Metadata.CurrentVersion && equalData(data, latest.Data) {
+		p.Add("secret", path, PlanSkip, "identical")
+		return
+	}
+	p.Add("secret", path, PlanUpdate, fmt.Sprintf("target at version %d, %d versions would be appended", current, len(secret.Versions)))
+}
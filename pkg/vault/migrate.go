@@ -0,0 +1,15 @@
+package vault
+
+// Migrate copies this Vault into target without an intermediate file. Each
+// record is restored as soon as it has been read from the source.
+func (c *Client) Migrate(target *Client, backupOpts BackupOptions, opts RestoreOptions) (BackupStats, error) {
+	r := newRestorer(target, opts)
+
+	if err := c.Backup(backupOpts, r); err != nil {
+		return r.stats, err
+	}
+
+	r.leaveSection()
+	r.checkPolicies()
+	return r.stats, nil
+}
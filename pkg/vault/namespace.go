@@ -0,0 +1,173 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SetNamespace makes every following request operate in namespace. An empty
+// namespace is the root namespace.
+func (c *Client) SetNamespace(namespace string) {
+	if namespace == "" {
+		c.client.ClearNamespace()
+		return
+	}
+	c.client.SetNamespace(namespace)
+}
+
+// inNamespace runs fn with the client switched to namespace. Callers must not
+// have requests in flight, since the namespace is shared by all of them.
+func (c *Client) inNamespace(namespace string, fn func() error) error {
+	previous := c.client.Namespace()
+	c.SetNamespace(namespace)
+	defer c.SetNamespace(previous)
+	return fn()
+}
+
+// listNamespaces returns the direct children of the current namespace. Vault
+// without namespace support has none.
+func (c *Client) listNamespaces() ([]NamespaceBackup, error) {
+	resp, err := c.client.Logical().List("sys/namespaces")
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, nil
+	}
+
+	keyInfo, _ := resp.Data["key_info"].(map[string]interface{})
+	keys := toStringSlice(resp.Data["keys"])
+	sort.Strings(keys)
+
+	var namespaces []NamespaceBackup
+	for _, key := range keys {
+		namespace := NamespaceBackup{Path: strings.TrimSuffix(key, "/") + "/"}
+		if info, ok := keyInfo[key].(map[string]interface{}); ok {
+			namespace.CustomMetadata = toStringMap(info["custom_metadata"])
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces, nil
+}
+
+// backupChildNamespaces backs up every namespace below the current one.
+// parent is the path of the current namespace relative to the backup root.
+func (c *Client) backupChildNamespaces(opts BackupOptions, sink BackupSink, parent string) error {
+	children, err := c.listNamespaces()
+	if err != nil {
+		return fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	current := c.client.Namespace()
+	for _, child := range children {
+		namespace := joinNamespace(current, child.Path)
+		child.Path = parent + child.Path
+		fmt.Printf("\nBacking up namespace %s...\n", child.Path)
+
+		if err := sink.BeginNamespace(child); err != nil {
+			return err
+		}
+
+		err := c.inNamespace(namespace, func() error {
+			if err := c.backupNamespace(opts, sink); err != nil {
+				return err
+			}
+			return c.backupChildNamespaces(opts, sink, child.Path)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to backup namespace %s: %w", child.Path, err)
+		}
+
+		if err := sink.EndNamespace(child.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureNamespace creates every missing namespace along path, which is
+// relative to root
+func (c *Client) ensureNamespace(root, path string, metadata map[string]string) error {
+	if strings.Trim(path, "/") == "" {
+		return nil
+	}
+
+	parent := root
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, name := range segments {
+		client := c.client.WithNamespace(parent)
+		resp, err := client.Logical().Read("sys/namespaces/" + name)
+		if err != nil {
+			return fmt.Errorf("failed to read namespace %s: %w", joinNamespace(parent, name), err)
+		}
+
+		if resp == nil {
+			data := map[string]interface{}{}
+			if i == len(segments)-1 && len(metadata) > 0 {
+				data["custom_metadata"] = metadata
+			}
+			if _, err := client.Logical().Write("sys/namespaces/"+name, data); err != nil {
+				return fmt.Errorf("failed to create namespace %s: %w", joinNamespace(parent, name), err)
+			}
+			fmt.Printf("  Created namespace %s\n", joinNamespace(parent, name))
+		}
+
+		parent = joinNamespace(parent, name)
+	}
+	return nil
+}
+
+// namespaceExists reports whether path, relative to root, exists
+func (c *Client) namespaceExists(root, path string) (bool, error) {
+	if strings.Trim(path, "/") == "" {
+		return true, nil
+	}
+
+	parent := root
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		resp, err := c.client.WithNamespace(parent).Logical().Read("sys/namespaces/" + name)
+		if err != nil {
+			return false, err
+		}
+		if resp == nil {
+			return false, nil
+		}
+		parent = joinNamespace(parent, name)
+	}
+	return true, nil
+}
+
+// mapNamespace applies the longest matching rename to a namespace path. The
+// result keeps the trailing slash of namespace paths; "" is the root.
+func mapNamespace(path string, mapping map[string]string) string {
+	var prefix, target string
+	for from, to := range mapping {
+		from = strings.Trim(from, "/") + "/"
+		if strings.HasPrefix(path, from) && len(from) > len(prefix) {
+			prefix, target = from, strings.Trim(to, "/")
+		}
+	}
+	if prefix == "" {
+		return path
+	}
+
+	rest := strings.TrimPrefix(path, prefix)
+	if target == "" {
+		return rest
+	}
+	return target + "/" + rest
+}
+
+func joinNamespace(parent, child string) string {
+	parent = strings.Trim(parent, "/")
+	child = strings.Trim(child, "/")
+	if parent == "" {
+		return child
+	}
+	if child == "" {
+		return parent
+	}
+	return parent + "/" + child
+}
@@ -0,0 +1,49 @@
+package vault
+
+import "testing"
+
+func TestMapNamespace(t *testing.T) {
+	mapping := map[string]string{
+		"team-a":      "teams/a",
+		"team-a/prod": "production/a/",
+		"legacy/":     "",
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"team-a/", "teams/a/"},
+		{"team-a/dev/", "teams/a/dev/"},
+		{"team-a/prod/", "production/a/"},
+		{"team-a/prod/eu/", "production/a/eu/"},
+		{"team-ab/", "team-ab/"},
+		{"legacy/", ""},
+		{"legacy/apps/", "apps/"},
+		{"other/", "other/"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := mapNamespace(tt.path, mapping); got != tt.want {
+			t.Errorf("mapNamespace(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestJoinNamespace(t *testing.T) {
+	tests := []struct {
+		parent, child, want string
+	}{
+		{"", "team-a", "team-a"},
+		{"team-a/", "prod/", "team-a/prod"},
+		{"team-a", "", "team-a"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := joinNamespace(tt.parent, tt.child); got != tt.want {
+			t.Errorf("joinNamespace(%q, %q) = %q, want %q", tt.parent, tt.child, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// PasswordStatus is the outcome of setting a password in one mount
+type PasswordStatus string
+
+const (
+	PasswordUpdated  PasswordStatus = "updated"
+	PasswordNotFound PasswordStatus = "not found"
+	PasswordFailed   PasswordStatus = "failed"
+
+	// PasswordFiltered is a credential for a userpass mount that was not
+	// selected, so it was left alone
+	PasswordFiltered PasswordStatus = "filtered"
+)
+
+type PasswordResult struct {
+	Mount    string
+	Username string
+	Status   PasswordStatus
+	Err      error
+}
+
+// ParseCredentials reads passwords to set as JSON or CSV. format is "json",
+// "csv" or "" to detect it from the content. JSON is either an object of
+// username:password pairs or a credentials report; CSV rows are
+// username,password[,mount] with an optional header row. Without a mount a
+// password is set in every userpass mount that has the user.
+func ParseCredentials(r io.Reader, format string) ([]Credential, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if format == "" {
+		format = "csv"
+		if bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("[")) {
+			format = "json"
+		}
+	}
+
+	switch format {
+	case "json":
+		return parseCredentialsJSON(trimmed)
+	case "csv":
+		return parseCredentialsCSV(trimmed)
+	}
+	return nil, fmt.Errorf("invalid credentials format %q: must be json or csv", format)
+}
+
+func parseCredentialsJSON(data []byte) ([]Credential, error) {
+	var credentials []Credential
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &credentials); err != nil {
+			return nil, fmt.Errorf("failed to parse credentials: %w", err)
+		}
+		return credentials, nil
+	}
+
+	var pairs map[string]string
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	for _, username := range unionKeys(pairs, nil) {
+		credentials = append(credentials, Credential{Username: username, Password: pairs[username]})
+	}
+	return credentials, nil
+}
+
+func parseCredentialsCSV(data []byte) ([]Credential, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	if len(rows) > 0 && strings.EqualFold(rows[0][0], "username") {
+		rows = rows[1:]
+	}
+
+	var credentials []Credential
+	for i, row := range rows {
+		if len(row) < 2 || len(row) > 3 {
+			return nil, fmt.Errorf("failed to parse credentials: row %d must be username,password[,mount]", i+1)
+		}
+		credential := Credential{Username: row[0], Password: row[1]}
+		if len(row) == 3 {
+			credential.Mount = row[2]
+		}
+		credentials = append(credentials, credential)
+	}
+	return credentials, nil
+}
+
+// UserpassMounts returns the paths of all userpass auth methods, without the
+// trailing slash
+func (c *Client) UserpassMounts() ([]string, error) {
+	auths, err := c.client.Sys().ListAuth()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list auth methods: %w", err)
+	}
+
+	var mounts []string
+	for path, auth := range auths {
+		if auth.Type == "userpass" {
+			mounts = append(mounts, strings.TrimSuffix(path, "/"))
+		}
+	}
+	sort.Strings(mounts)
+	return mounts, nil
+}
+
+// SetUserpassPasswords sets each password in the credential's mount, or in
+// every selected mount without one. all are the userpass mounts of the
+// target, selected the ones to update. Only users that already exist are
+// written, since writing the password of a missing user would create it.
+func (c *Client) SetUserpassPasswords(credentials []Credential, all, selected []string) []PasswordResult {
+	var results []PasswordResult
+	for _, credential := range credentials {
+		targets := selected
+		if credential.Mount != "" {
+			mount := strings.TrimSuffix(credential.Mount, "/")
+			if contains(all, mount) && !contains(selected, mount) {
+				results = append(results, PasswordResult{
+					Mount:    mount,
+					Username: credential.Username,
+					Status:   PasswordFiltered,
+				})
+				continue
+			}
+			if !contains(selected, mount) {
+				results = append(results, PasswordResult{
+					Mount:    mount,
+					Username: credential.Username,
+					Status:   PasswordFailed,
+					Err:      fmt.Errorf("not a userpass mount"),
+				})
+				continue
+			}
+			targets = []string{mount}
+		}
+
+		for _, mount := range targets {
+			results = append(results, c.setUserpassPassword(mount, credential.Username, credential.Password))
+		}
+	}
+	return results
+}
+
+func (c *Client) setUserpassPassword(mount, username, password string) PasswordResult {
+	result := PasswordResult{Mount: mount, Username: username}
+	userPath := authBasePath(mount) + "/users/" + username
+
+	resp, err := c.client.Logical().Read(userPath)
+	if err != nil {
+		result.Status, result.Err = PasswordFailed, err
+		return result
+	}
+	if resp == nil {
+		result.Status = PasswordNotFound
+		return result
+	}
+
+	if _, err := c.client.Logical().Write(userPath+"/password", map[string]interface{}{
+		"password": password,
+	}); err != nil {
+		result.Status, result.Err = PasswordFailed, err
+		return result
+	}
+	result.Status = PasswordUpdated
+	return result
+}
@@ -0,0 +1,81 @@
+package vault
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCredentials(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		format string
+		want   []Credential
+	}{
+		{
+			name:  "json pairs",
+			input: `{"bob": "b-pass", "alice": "a-pass"}`,
+			want:  []Credential{{Username: "alice", Password: "a-pass"}, {Username: "bob", Password: "b-pass"}},
+		},
+		{
+			name:  "credentials report",
+			input: "[\n  {\"mount\": \"userpass\", \"username\": \"alice\", \"password\": \"a-pass\"}\n]\n",
+			want:  []Credential{{Mount: "userpass", Username: "alice", Password: "a-pass"}},
+		},
+		{
+			name:  "csv with header",
+			input: "username,password,mount\nalice,a-pass,userpass\nbob,\"b,pass\"\n",
+			want:  []Credential{{Mount: "userpass", Username: "alice", Password: "a-pass"}, {Username: "bob", Password: "b,pass"}},
+		},
+		{
+			name:  "csv without header",
+			input: "alice,a-pass\n",
+			want:  []Credential{{Username: "alice", Password: "a-pass"}},
+		},
+		{
+			name:   "explicit format",
+			input:  "{weird,pass\n",
+			format: "csv",
+			want:   []Credential{{Username: "{weird", Password: "pass"}},
+		},
+		{
+			name:  "empty",
+			input: "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCredentials(strings.NewReader(tt.input), tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCredentials = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCredentialsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		format string
+	}{
+		{"invalid json", `{"alice": `, ""},
+		{"json with non-string password", `{"alice": 1}`, ""},
+		{"csv row without password", "alice\n", ""},
+		{"csv row with extra fields", "alice,a-pass,userpass,extra\n", ""},
+		{"unknown format", "alice,a-pass\n", "yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCredentials(strings.NewReader(tt.input), tt.format); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	RegisterEngineHandler("pki", pkiHandler{})
+}
+
+// PKIBackup holds the CA of a pki mount. Vault does not hand out issuer
+// private keys, so only their metadata is backed up; the keys themselves are
+// supplied on restore (see LoadPKIKeys).
+type PKIBackup struct {
+	Issuers []PKIIssuerBackup `json:"issuers,omitempty"`
+	Keys    []RoleBackup      `json:"keys,omitempty"`
+	Roles   []RoleBackup      `json:"roles,omitempty"`
+
+	// IssuersConfig is config/issuers, including the default issuer ID
+	IssuersConfig map[string]interface{} `json:"issuers_config,omitempty"`
+	URLs          map[string]interface{} `json:"urls,omitempty"`
+	CRL           map[string]interface{} `json:"crl,omitempty"`
+}
+
+type PKIIssuerBackup struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name,omitempty"`
+	KeyID       string                 `json:"key_id,omitempty"`
+	Certificate string                 `json:"certificate"`
+	Config      map[string]interface{} `json:"config,omitempty"`
+}
+
+// pkiIssuerFields are the issuer settings carried over on restore
+var pkiIssuerFields = []string{
+	"leaf_not_after_behavior",
+	"usage",
+	"manual_chain",
+	"revocation_signature_algorithm",
+	"issuing_certificates",
+	"crl_distribution_points",
+	"ocsp_servers",
+	"enable_aia_url_templating",
+}
+
+// PKIKeys maps "<mount>/<key name or key ID>" to a PEM private key
+type PKIKeys map[string]string
+
+// LoadPKIKeys reads PEM private keys from dir/<mount>/<key>.pem, where key is
+// the key name or ID in the source mount
+func LoadPKIKeys(dir string) (PKIKeys, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PKI keys: %w", err)
+	}
+
+	keys := make(PKIKeys)
+	for _, file := range files {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PKI key: %w", err)
+		}
+		mount := filepath.Base(filepath.Dir(file))
+		name := strings.TrimSuffix(filepath.Base(file), ".pem")
+		keys[mount+"/"+name] = string(pem)
+	}
+	return keys, nil
+}
+
+// key finds the private key of an issuer, by name or ID, under the source or
+// target mount
+func (k PKIKeys) key(sourceMount, targetMount string, key RoleBackup) string {
+	for _, mount := range []string{targetMount, sourceMount} {
+		mount = strings.TrimSuffix(mount, "/")
+		for _, ref := range []string{stringValue(key.Data["key_name"]), key.Name} {
+			if ref == "" {
+				continue
+			}
+			if pem, ok := k[mount+"/"+ref]; ok {
+				return pem
+			}
+		}
+	}
+	return ""
+}
+
+type pkiHandler struct{}
+
+func (pkiHandler) Backup(c *Client, engine *SecretEngineBackup) error {
+	pki := &PKIBackup{}
+
+	issuerIDs, err := c.listKeys(engine.Path + "issuers")
+	if err != nil {
+		return fmt.Errorf("failed to list PKI issuers: %w", err)
+	}
+	for _, id := range issuerIDs {
+		resp, err := c.client.Logical().Read(engine.Path + "issuer/" + id)
+		if err != nil || resp == nil || resp.Data == nil {
+			fmt.Printf("    Warning: failed to read issuer %s: %v\n", id, err)
+			continue
+		}
+
+		issuer := PKIIssuerBackup{
+			ID:          id,
+			Name:        stringValue(resp.Data["issuer_name"]),
+			KeyID:       stringValue(resp.Data["key_id"]),
+			Certificate: stringValue(resp.Data["certificate"]),
+			Config:      make(map[string]interface{}),
+		}
+		for _, field := range pkiIssuerFields {
+			if v, ok := resp.Data[field]; ok {
+				issuer.Config[field] = v
+			}
+		}
+		pki.Issuers = append(pki.Issuers, issuer)
+	}
+
+	if pki.Keys, err = c.readEntries(engine.Path+"keys", engine.Path+"key/", nil); err != nil {
+		return fmt.Errorf("failed to backup PKI keys: %w", err)
+	}
+	if pki.Roles, err = c.readEntries(engine.Path+"roles", engine.Path+"roles/", nil); err != nil {
+		return fmt.Errorf("failed to backup PKI roles: %w", err)
+	}
+
+	pki.IssuersConfig = c.readPKIConfig(engine.Path + "config/issuers")
+	pki.URLs = c.readPKIConfig(engine.Path + "config/urls")
+	pki.CRL = c.readPKIConfig(engine.Path + "config/crl")
+
+	engine.PKI = pki
+	fmt.Printf("    Backed up %d issuers, %d keys and %d roles (private keys are not included)\n",
+		len(pki.Issuers), len(pki.Keys), len(pki.Roles))
+	return nil
+}
+
+func (c *Client) readPKIConfig(path string) map[string]interface{} {
+	resp, err := c.client.Logical().Read(path)
+	if err != nil {
+		fmt.Printf("    Warning: failed to read %s: %v\n", path, err)
+		return nil
+	}
+	if resp == nil {
+		return nil
+	}
+	return resp.Data
+}
+
+// Restore imports every issuer certificate, with its private key when one is
+// supplied, then reapplies names, settings, the default issuer, roles and
+// the URL and CRL config. Issuer IDs change, so references are remapped.
+func (pkiHandler) Restore(c *Client, mountPath string, engine SecretEngineBackup, opts RestoreOptions) error {
+	if engine.PKI == nil {
+		return nil
+	}
+	pki := engine.PKI
+	failed := 0
+
+	keys := make(map[string]RoleBackup)
+	for _, key := range pki.Keys {
+		keys[key.Name] = key
+	}
+
+	var withoutKey []string
+	for _, issuer := range pki.Issuers {
+		bundle := issuer.Certificate
+		if key, ok := keys[issuer.KeyID]; ok && issuer.KeyID != "" {
+			if pem := opts.PKIKeys.key(engine.Path, mountPath, key); pem != "" {
+				bundle = strings.TrimSpace(pem) + "\n" + issuer.Certificate
+			}
+		}
+		if bundle == issuer.Certificate {
+			withoutKey = append(withoutKey, pkiIssuerLabel(issuer))
+		}
+
+		_, err := c.client.Logical().Write(mountPath+"issuers/import/bundle", map[string]interface{}{
+			"pem_bundle": bundle,
+		})
+		if err != nil {
+			c.reportFailure("    Warning: failed to import issuer %s: %v\n", pkiIssuerLabel(issuer), err)
+			failed++
+		}
+	}
+
+	issuerIDs, err := c.mapPKIIssuers(mountPath, pki.Issuers)
+	if err != nil {
+		return err
+	}
+
+	restored := 0
+	for _, issuer := range pki.Issuers {
+		targetID, ok := issuerIDs[issuer.ID]
+		if !ok {
+			continue
+		}
+		restored++
+
+		settings := make(map[string]interface{})
+		for k, v := range issuer.Config {
+			settings[k] = v
+		}
+		if issuer.Name != "" {
+			settings["issuer_name"] = issuer.Name
+		}
+		if chain := toStringSlice(settings["manual_chain"]); len(chain) > 0 {
+			settings["manual_chain"] = remapIDs(chain, issuerIDs)
+		}
+		if _, err := c.client.Logical().Write(mountPath+"issuer/"+targetID, settings); err != nil {
+			c.reportFailure("    Warning: failed to configure issuer %s: %v\n", pkiIssuerLabel(issuer), err)
+			failed++
+		}
+
+		// The key is named after the source key once it exists in the target
+		key, ok := keys[issuer.KeyID]
+		if !ok || stringValue(key.Data["key_name"]) == "" {
+			continue
+		}
+		resp, err := c.client.Logical().Read(mountPath + "issuer/" + targetID)
+		if err != nil || resp == nil || resp.Data == nil || stringValue(resp.Data["key_id"]) == "" {
+			continue
+		}
+		_, err = c.client.Logical().Write(mountPath+"key/"+stringValue(resp.Data["key_id"]), map[string]interface{}{
+			"key_name": key.Data["key_name"],
+		})
+		if err != nil {
+			c.reportFailure("    Warning: failed to name key %s: %v\n", key.Data["key_name"], err)
+			failed++
+		}
+	}
+
+	if len(pki.IssuersConfig) > 0 {
+		config := make(map[string]interface{})
+		for k, v := range pki.IssuersConfig {
+			config[k] = v
+		}
+		if id, ok := issuerIDs[stringValue(config["default"])]; ok {
+			config["default"] = id
+		} else {
+			delete(config, "default")
+		}
+		if _, err := c.client.Logical().Write(mountPath+"config/issuers", config); err != nil {
+			c.reportFailure("    Warning: failed to set the default issuer: %v\n", err)
+			failed++
+		}
+	}
+
+	roles := 0
+	for _, role := range pki.Roles {
+		data := make(map[string]interface{})
+		for k, v := range role.Data {
+			data[k] = v
+		}
+		if id, ok := issuerIDs[stringValue(data["issuer_ref"])]; ok {
+			data["issuer_ref"] = id
+		}
+		if _, err := c.client.Logical().Write(mountPath+"roles/"+role.Name, data); err != nil {
+			c.reportFailure("    Warning: failed to restore role %s: %v\n", role.Name, err)
+			failed++
+			continue
+		}
+		roles++
+	}
+
+	if len(pki.URLs) > 0 {
+		if _, err := c.client.Logical().Write(mountPath+"config/urls", pki.URLs); err != nil {
+			c.reportFailure("    Warning: failed to restore config/urls: %v\n", err)
+			failed++
+		}
+	}
+	if len(pki.CRL) > 0 {
+		if _, err := c.client.Logical().Write(mountPath+"config/crl", pki.CRL); err != nil {
+			c.reportFailure("    Warning: failed to restore config/crl: %v\n", err)
+			failed++
+		}
+	}
+
+	fmt.Printf("    Restored %d of %d issuers and %d of %d roles\n", restored, len(pki.Issuers), roles, len(pki.Roles))
+	for _, label := range withoutKey {
+		fmt.Printf("    Warning: issuer %s was imported without its private key and cannot issue certificates; supply the key with --pki-keys\n", label)
+	}
+	return failedEntries(failed)
+}
+
+// mapPKIIssuers matches the source issuers to the issuers in the target by
+// certificate, returning source ID => target ID
+func (c *Client) mapPKIIssuers(mountPath string, issuers []PKIIssuerBackup) (map[string]string, error) {
+	byCert, err := c.pkiIssuersByCert(mountPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	for _, issuer := range issuers {
+		if id, ok := byCert[strings.TrimSpace(issuer.Certificate)]; ok {
+			result[issuer.ID] = id
+		}
+	}
+	return result, nil
+}
+
+// pkiIssuersByCert returns the issuer IDs of a pki mount by certificate
+func (c *Client) pkiIssuersByCert(mountPath string) (map[string]string, error) {
+	ids, err := c.listKeys(mountPath + "issuers")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list issuers of %s: %w", mountPath, err)
+	}
+
+	result := make(map[string]string)
+	for _, id := range ids {
+		resp, err := c.client.Logical().Read(mountPath + "issuer/" + id)
+		if err != nil || resp == nil || resp.Data == nil {
+			continue
+		}
+		result[strings.TrimSpace(stringValue(resp.Data["certificate"]))] = id
+	}
+	return result, nil
+}
+
+func remapIDs(ids []string, mapping map[string]string) []string {
+	var result []string
+	for _, id := range ids {
+		if mapped, ok := mapping[id]; ok {
+			id = mapped
+		}
+		result = append(result, id)
+	}
+	return result
+}
+
+func pkiIssuerLabel(issuer PKIIssuerBackup) string {
+	if issuer.Name != "" {
+		return issuer.Name
+	}
+	return issuer.ID
+}
+
+func (pkiHandler) Diff(report *DiffReport, mountPath string, left, right SecretEngineBackup, opts DiffOptions) {
+	l, r := left.PKI, right.PKI
+	if l == nil {
+		l = &PKIBackup{}
+	}
+	if r == nil {
+		r = &PKIBackup{}
+	}
+
+	// Issuer IDs differ between clusters, so issuers are matched by name
+	diffAuthEntries(report, "pki issuer", mountPath+"issuer/", pkiIssuerSummaries(l), pkiIssuerSummaries(r))
+	diffAuthEntries(report, "pki role", mountPath+"roles/", rolesToMap(l.Roles), rolesToMap(r.Roles))
+	diffAuthEntries(report, "pki config", mountPath+"config/",
+		map[string]map[string]interface{}{"urls": l.URLs, "crl": l.CRL},
+		map[string]map[string]interface{}{"urls": r.URLs, "crl": r.CRL})
+}
+
+func pkiIssuerSummaries(pki *PKIBackup) map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	for _, issuer := range pki.Issuers {
+		summary := map[string]interface{}{
+			"certificate": strings.TrimSpace(issuer.Certificate),
+			"default":     issuer.ID == stringValue(pki.IssuersConfig["default"]),
+		}
+		for k, v := range issuer.Config {
+			if k != "manual_chain" {
+				summary[k] = v
+			}
+		}
+		result[pkiIssuerLabel(issuer)] = summary
+	}
+	return result
+}
+
+func (pkiHandler) Plan(p *PlanContext, mountPath string, engine SecretEngineBackup) {
+	if engine.PKI == nil {
+		return
+	}
+	pki := engine.PKI
+
+	existing := make(map[string]bool)
+	ids, err := p.List(mountPath + "issuers")
+	if err != nil {
+		p.Add("pki issuer", mountPath+"issuers", PlanConflict, err.Error())
+		return
+	}
+	for _, id := range ids {
+		resp, err := p.Read(mountPath + "issuer/" + id)
+		if err == nil && resp != nil && resp.Data != nil {
+			existing[strings.TrimSpace(stringValue(resp.Data["certificate"]))] = true
+		}
+	}
+
+	keys := make(map[string]RoleBackup)
+	for _, key := range pki.Keys {
+		keys[key.Name] = key
+	}
+
+	for _, issuer := range pki.Issuers {
+		path := mountPath + "issuer/" + pkiIssuerLabel(issuer)
+		key, hasKey := keys[issuer.KeyID]
+		switch {
+		case existing[strings.TrimSpace(issuer.Certificate)]:
+			p.Add("pki issuer", path, PlanSkip, "certificate already imported")
+		case hasKey && p.Options().PKIKeys.key(engine.Path, mountPath, key) != "":
+			p.Add("pki issuer", path, PlanCreate, "with private key")
+		default:
+			p.Add("pki issuer", path, PlanCreate, "certificate only, no private key supplied")
+		}
+	}
+
+	for _, role := range pki.Roles {
+		p.Entry("pki role", mountPath+"roles/"+role.Name, role.Data, "")
+	}
+	if len(pki.URLs) > 0 {
+		p.Entry("pki config", mountPath+"config/urls", pki.URLs, "")
+	}
+	if len(pki.CRL) > 0 {
+		p.Entry("pki config", mountPath+"config/crl", pki.CRL, "")
+	}
+}
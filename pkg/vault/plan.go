@@ -0,0 +1,436 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+type PlanAction string
+
+const (
+	PlanCreate   PlanAction = "create"
+	PlanUpdate   PlanAction = "update"
+	PlanSkip     PlanAction = "skip"
+	PlanConflict PlanAction = "conflict"
+)
+
+type PlanItem struct {
+	Namespace string     `json:"namespace,omitempty"`
+	Kind      string     `json:"kind"`
+	Path      string     `json:"path"`
+	Action    PlanAction `json:"action"`
+	Detail    string     `json:"detail,omitempty"`
+}
+
+type Plan struct {
+	Items []PlanItem `json:"items"`
+
+	// namespace is stamped on items as they are added
+	namespace string
+}
+
+func (p *Plan) Count(action PlanAction) int {
+	count := 0
+	for _, item := range p.Items {
+		if item.Action == action {
+			count++
+		}
+	}
+	return count
+}
+
+func (p *Plan) add(kind, path string, action PlanAction, detail string) {
+	p.Items = append(p.Items, PlanItem{Namespace: p.namespace, Kind: kind, Path: path, Action: action, Detail: detail})
+}
+
+// Plan reads the target Vault and reports what Restore would do with each
+// record in source. Nothing is written.
+func (c *Client) Plan(source BackupSource, opts RestoreOptions) (*Plan, error) {
+	p := &planner{c: c, opts: opts, plan: &Plan{}, root: c.client.Namespace()}
+	defer c.SetNamespace(p.root)
+	if err := source.Replay(p); err != nil {
+		return p.plan, err
+	}
+	p.checkPolicies()
+	return p.plan, nil
+}
+
+// planner is a BackupSink that compares each record against the target.
+type planner struct {
+	c      *Client
+	opts   RestoreOptions
+	plan   *Plan
+	mounts map[string]*api.MountOutput
+	auths  map[string]*api.AuthMount
+
+	// engine is the mount whose secrets are being planned, nil when it is
+	// filtered out or conflicts with the target. target is its rewritten
+	// path, sources the engine paths seen in the current namespace.
+	engine  *SecretEngineBackup
+	target  string
+	secrets SecretPlanner
+	sources []string
+
+	// policies collects policy paths, planned the mounts that would exist
+	// in the target after the restore
+	policies policyCheck
+	planned  []string
+
+	// root is the target namespace the plan started in, namespaces the stack
+	// of mapped child namespaces. In a namespace that does not exist yet
+	// (missing) everything is planned as created without reading the target.
+	root       string
+	namespaces []string
+	missing    bool
+}
+
+func (p *planner) WriteHeader(header BackupHeader) error {
+	p.policies.filtered = len(header.Engines) > 0
+	return p.loadTarget()
+}
+
+// loadTarget lists the mounts and auth methods of the current namespace
+func (p *planner) loadTarget() error {
+	p.mounts = make(map[string]*api.MountOutput)
+	p.auths = make(map[string]*api.AuthMount)
+	if p.missing {
+		return nil
+	}
+
+	mounts, err := p.c.client.Sys().ListMounts()
+	if err != nil {
+		return fmt.Errorf("failed to list target mounts: %w", err)
+	}
+	p.mounts = mounts
+
+	auths, err := p.c.client.Sys().ListAuth()
+	if err != nil {
+		return fmt.Errorf("failed to list target auth methods: %w", err)
+	}
+	p.auths = auths
+
+	return nil
+}
+
+func (p *planner) WriteEngine(engine SecretEngineBackup) error {
+	p.engine = nil
+	p.sources = append(p.sources, engine.Path)
+	p.policies.addSource(engine.Path)
+
+	if len(p.opts.Engines) > 0 && !contains(p.opts.Engines, strings.TrimSuffix(engine.Path, "/")) {
+		return nil
+	}
+
+	target := p.opts.Rewrite.Mount(engine.Path)
+	p.policies.addSource(target)
+	p.planned = append(p.planned, target)
+	existing, ok := p.mounts[target]
+	switch {
+	case !ok:
+		p.plan.add("mount", target, PlanCreate, "type "+engine.Type+renamedFrom(engine.Path, target))
+	case existing.Type != engine.Type:
+		p.plan.add("mount", target, PlanConflict, fmt.Sprintf("target type is %s, backup type is %s", existing.Type, engine.Type))
+		return nil
+	case kvVersion(engine) == 2 && existing.Options["version"] != "2":
+		p.plan.add("mount", target, PlanConflict, "target is KV v1, backup is KV v2")
+		return nil
+	case kvVersion(engine) == 1 && existing.Options["version"] == "2":
+		p.plan.add("mount", target, PlanConflict, "target is KV v2, backup is KV v1")
+		return nil
+	default:
+		if changes := tuneChanges(existing.Config, engine.Config); len(changes) > 0 {
+			p.plan.add("mount", target, PlanUpdate, "tune "+tuneChangeFields(changes))
+		} else {
+			p.plan.add("mount", target, PlanSkip, "already mounted")
+		}
+	}
+
+	handler := engineHandler(engine.Type)
+	if planner, ok := handler.(EnginePlanner); ok {
+		planner.Plan(&PlanContext{p}, target, engine)
+	}
+
+	p.engine = &engine
+	p.target = target
+	p.secrets, _ = handler.(SecretPlanner)
+	return nil
+}
+
+func (p *planner) WriteSecret(enginePath string, secret SecretBackup) error {
+	if p.engine == nil || p.engine.Path != enginePath {
+		return nil
+	}
+
+	if p.secrets == nil {
+		return nil
+	}
+
+	secret.Path = p.opts.Rewrite.Secret(enginePath, secret.Path)
+	p.secrets.PlanSecret(&PlanContext{p}, p.target, *p.engine, secret)
+	return nil
+}
+
+func (p *planner) EndEngine(enginePath string) error {
+	p.engine = nil
+	return nil
+}
+
+func (p *planner) WritePolicy(policy PolicyBackup) error {
+	if p.opts.SkipPolicies {
+		return nil
+	}
+
+	rules, parsed, err := rewritePolicy(policy.Policy, p.opts.Rewrite, p.sources)
+	if err != nil {
+		p.plan.add("policy", policy.Name, PlanConflict, "restored unchanged: "+err.Error())
+	}
+	if parsed != nil {
+		p.policies.add(policy.Name, parsed)
+	}
+
+	var existing string
+	var readErr error
+	if !p.missing {
+		existing, readErr = p.c.client.Sys().GetPolicy(policy.Name)
+	}
+	switch {
+	case readErr != nil:
+		p.plan.add("policy", policy.Name, PlanConflict, readErr.Error())
+	case existing == "":
+		p.plan.add("policy", policy.Name, PlanCreate, "")
+	case strings.TrimSpace(existing) == strings.TrimSpace(rules):
+		p.plan.add("policy", policy.Name, PlanSkip, "identical")
+	default:
+		p.plan.add("policy", policy.Name, PlanUpdate, "rules differ")
+	}
+
+	return nil
+}
+
+func (p *planner) WriteAuthMethod(auth AuthMethodBackup) error {
+	p.policies.addSource("auth/" + auth.Path)
+	if p.opts.SkipAuth {
+		return nil
+	}
+	p.planned = append(p.planned, "auth/"+auth.Path)
+
+	existing, ok := p.auths[auth.Path]
+	switch {
+	case !ok:
+		p.plan.add("auth", auth.Path, PlanCreate, "type "+auth.Type)
+	case existing.Type != auth.Type:
+		p.plan.add("auth", auth.Path, PlanConflict, fmt.Sprintf("target type is %s, backup type is %s", existing.Type, auth.Type))
+		return nil
+	default:
+		if changes := tuneChanges(existing.Config, auth.Config); len(changes) > 0 {
+			p.plan.add("auth", auth.Path, PlanUpdate, "tune "+tuneChangeFields(changes))
+		} else {
+			p.plan.add("auth", auth.Path, PlanSkip, "already enabled")
+		}
+	}
+
+	if planner, ok := authHandler(auth.Type).(AuthPlanner); ok {
+		planner.Plan(&PlanContext{p}, auth)
+	}
+
+	return nil
+}
+
+func (p *planner) WriteEntity(entity EntityBackup) error {
+	if p.opts.SkipIdentity {
+		return nil
+	}
+	p.planIdentity("entity", "identity/entity/name/"+entity.Name, entity.Policies)
+	return nil
+}
+
+func (p *planner) WriteGroup(group GroupBackup) error {
+	if p.opts.SkipIdentity {
+		return nil
+	}
+	p.planIdentity("group", "identity/group/name/"+group.Name, group.Policies)
+	return nil
+}
+
+func (p *planner) BeginNamespace(namespace NamespaceBackup) error {
+	p.checkPolicies()
+
+	target := mapNamespace(namespace.Path, p.opts.NamespaceMap)
+	detail := ""
+	if target != namespace.Path {
+		detail = "from " + namespace.Path
+	}
+
+	// Everything below a missing namespace is missing as well
+	if !p.missing {
+		exists, err := p.c.namespaceExists(p.root, target)
+		if err != nil {
+			return fmt.Errorf("failed to read namespace %s: %w", target, err)
+		}
+		p.missing = !exists
+	}
+
+	p.plan.namespace = ""
+	if p.missing {
+		p.plan.add("namespace", target, PlanCreate, detail)
+	} else {
+		p.plan.add("namespace", target, PlanSkip, strings.TrimSpace("exists "+detail))
+	}
+
+	p.namespaces = append(p.namespaces, target)
+	return p.enterNamespace(target)
+}
+
+func (p *planner) EndNamespace(path string) error {
+	if len(p.namespaces) == 0 {
+		return fmt.Errorf("unexpected end of namespace %s", path)
+	}
+	p.checkPolicies()
+	p.namespaces = p.namespaces[:len(p.namespaces)-1]
+
+	parent := ""
+	if len(p.namespaces) > 0 {
+		parent = p.namespaces[len(p.namespaces)-1]
+	}
+
+	exists, err := p.c.namespaceExists(p.root, parent)
+	if err != nil {
+		return fmt.Errorf("failed to read namespace %s: %w", parent, err)
+	}
+	p.missing = !exists
+	return p.enterNamespace(parent)
+}
+
+func (p *planner) enterNamespace(path string) error {
+	p.plan.namespace = path
+	p.sources = nil
+	if !p.missing {
+		p.c.SetNamespace(joinNamespace(p.root, path))
+	}
+	return p.loadTarget()
+}
+
+// checkPolicies plans a conflict for each policy path in the current
+// namespace that would not refer to a mount after the restore
+func (p *planner) checkPolicies() {
+	target := append([]string{}, p.planned...)
+	for path := range p.mounts {
+		target = append(target, path)
+	}
+	for path := range p.auths {
+		target = append(target, "auth/"+path)
+	}
+
+	for _, issue := range p.policies.check(target) {
+		detail := fmt.Sprintf("policy %s: %s is not mounted in the target", issue.Policy, issue.Mount)
+		switch {
+		case !issue.InSource && issue.Filtered:
+			detail = fmt.Sprintf("policy %s: %s is not in the target, nor in the backup, which was limited with --engines", issue.Policy, issue.Mount)
+		case !issue.InSource:
+			detail = fmt.Sprintf("policy %s: %s exists in neither cluster", issue.Policy, issue.Mount)
+		}
+		p.plan.add("policy path", issue.Path, PlanConflict, detail)
+	}
+	p.policies.reset()
+	p.planned = nil
+}
+
+// renamedFrom describes where a rewritten mount comes from
+func renamedFrom(source, target string) string {
+	if source == target {
+		return ""
+	}
+	return ", from " + source
+}
+
+// read reads from the target, or finds nothing in a missing namespace
+func (p *planner) read(path string) (*api.Secret, error) {
+	if p.missing {
+		return nil, nil
+	}
+	return p.c.client.Logical().Read(path)
+}
+
+// planIdentity compares an entity or group by name. Its ID always differs
+// between clusters, so only the attached policies are compared.
+func (p *planner) planIdentity(kind, path string, policies []string) {
+	resp, err := p.read(path)
+	switch {
+	case err != nil:
+		p.plan.add(kind, path, PlanConflict, err.Error())
+	case resp == nil || resp.Data == nil:
+		p.plan.add(kind, path, PlanCreate, "")
+	case equalStringSets(toStringSlice(resp.Data["policies"]), policies):
+		p.plan.add(kind, path, PlanSkip, "identical policies")
+	default:
+		p.plan.add(kind, path, PlanUpdate, "policies differ")
+	}
+}
+
+// planAuthEntry compares a user or role with the target. A non-empty
+// alwaysUpdate marks entries that restore rewrites even when identical.
+func (p *planner) planAuthEntry(kind, path string, data map[string]interface{}, alwaysUpdate string) {
+	resp, err := p.read(path)
+	switch {
+	case err != nil:
+		p.plan.add(kind, path, PlanConflict, err.Error())
+	case resp == nil || resp.Data == nil:
+		p.plan.add(kind, path, PlanCreate, "")
+	case alwaysUpdate != "":
+		p.plan.add(kind, path, PlanUpdate, alwaysUpdate)
+	case equalData(resp.Data, data):
+		p.plan.add(kind, path, PlanSkip, "identical")
+	default:
+		p.plan.add(kind, path, PlanUpdate, "settings differ")
+	}
+}
+
+// latestVersion returns the newest version that is live in the backup
+func latestVersion(secret SecretBackup) *SecretVersion {
+	for i := len(secret.Versions) - 1; i >= 0; i-- {
+		version := secret.Versions[i]
+		if !version.Destroyed && version.Data != nil && !isDeletedVersion(version) {
+			return &secret.Versions[i]
+		}
+	}
+	return nil
+}
+
+func equalStringSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, s := range a {
+		seen[s]++
+	}
+	for _, s := range b {
+		if seen[s] == 0 {
+			return false
+		}
+		seen[s]--
+	}
+	return true
+}
+
+// equalData compares two decoded JSON values, ignoring differences between
+// json.Number and float64 representations.
+func equalData(a, b interface{}) bool {
+	return reflect.DeepEqual(normalizeJSON(a), normalizeJSON(b))
+}
+
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
@@ -0,0 +1,298 @@
+package vault
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/printer"
+)
+
+// builtinMounts exist in every Vault namespace
+var builtinMounts = []string{"sys/", "identity/", "cubbyhole/", "auth/token/"}
+
+// ParsedPolicy is an ACL policy broken into its path blocks. Everything else
+// in the policy is kept as parsed.
+type ParsedPolicy struct {
+	Paths []PolicyPath
+
+	file *ast.File
+	keys []*ast.ObjectKey
+}
+
+type PolicyPath struct {
+	Path         string
+	Capabilities []string
+}
+
+// ParsePolicy parses policy HCL (or JSON) the way Vault does
+func ParsePolicy(rules string) (*ParsedPolicy, error) {
+	file, err := hcl.Parse(rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse policy: root should be an object")
+	}
+
+	policy := &ParsedPolicy{file: file}
+	for _, item := range list.Filter("path").Items {
+		if len(item.Keys) == 0 {
+			return nil, fmt.Errorf("failed to parse policy: path block without a path")
+		}
+
+		key := item.Keys[0]
+		path, ok := key.Token.Value().(string)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse policy: invalid path %s", key.Token.Text)
+		}
+
+		policy.Paths = append(policy.Paths, PolicyPath{
+			Path:         path,
+			Capabilities: policyCapabilities(item.Val),
+		})
+		policy.keys = append(policy.keys, key)
+	}
+
+	return policy, nil
+}
+
+// policyCapabilities reads the capabilities of a path block, including the
+// legacy policy = "read" form
+func policyCapabilities(node ast.Node) []string {
+	obj, ok := node.(*ast.ObjectType)
+	if !ok {
+		return nil
+	}
+
+	var capabilities []string
+	for _, item := range obj.List.Filter("capabilities").Items {
+		if list, ok := item.Val.(*ast.ListType); ok {
+			for _, elem := range list.List {
+				if lit, ok := elem.(*ast.LiteralType); ok {
+					if s, ok := lit.Token.Value().(string); ok {
+						capabilities = append(capabilities, s)
+					}
+				}
+			}
+		}
+	}
+	for _, item := range obj.List.Filter("policy").Items {
+		if lit, ok := item.Val.(*ast.LiteralType); ok {
+			if s, ok := lit.Token.Value().(string); ok {
+				capabilities = append(capabilities, "policy="+s)
+			}
+		}
+	}
+	return capabilities
+}
+
+// Rewrite replaces every path with fn(path) and reports whether any changed
+func (p *ParsedPolicy) Rewrite(fn func(path string) string) bool {
+	changed := false
+	for i := range p.Paths {
+		path := fn(p.Paths[i].Path)
+		if path == p.Paths[i].Path {
+			continue
+		}
+		p.Paths[i].Path = path
+		p.keys[i].Token.Text = strconv.Quote(path)
+		changed = true
+	}
+	return changed
+}
+
+// HCL formats the policy. Policies written as JSON come back as HCL.
+func (p *ParsedPolicy) HCL() (string, error) {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, p.file); err != nil {
+		return "", fmt.Errorf("failed to format policy: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// rewritePolicy parses a policy and applies the rewrite rules to its paths.
+// The original text is returned unless a path changed.
+func rewritePolicy(rules string, rewrite *RewriteRules, mounts []string) (string, *ParsedPolicy, error) {
+	policy, err := ParsePolicy(rules)
+	if err != nil {
+		return rules, nil, err
+	}
+
+	if rewrite == nil || !policy.Rewrite(func(path string) string { return rewrite.PolicyPath(path, mounts) }) {
+		return rules, policy, nil
+	}
+
+	text, err := policy.HCL()
+	if err != nil {
+		return rules, policy, err
+	}
+	return text, policy, nil
+}
+
+// PolicyMountIssue is a policy path that refers to a mount missing from the
+// target. InSource is false when the backup does not have the mount either,
+// which only means the source lacks it if the backup was not Filtered.
+type PolicyMountIssue struct {
+	Policy   string
+	Path     string
+	Mount    string
+	InSource bool
+	Filtered bool
+}
+
+// policyCheck collects the paths of the policies in a namespace so they can
+// be checked once its engines and auth methods are restored
+type policyCheck struct {
+	// sources are the mounts in the backup; auth methods as auth/<path>.
+	// filtered is set when the backup holds only some engines.
+	sources  []string
+	filtered bool
+	policies []string
+	paths    map[string][]string
+}
+
+func (p *policyCheck) addSource(mount string) {
+	p.sources = append(p.sources, mount)
+}
+
+func (p *policyCheck) add(name string, policy *ParsedPolicy) {
+	if p.paths == nil {
+		p.paths = make(map[string][]string)
+	}
+	if _, ok := p.paths[name]; !ok {
+		p.policies = append(p.policies, name)
+	}
+	for _, path := range policy.Paths {
+		p.paths[name] = append(p.paths[name], path.Path)
+	}
+}
+
+// check returns the policy paths that match none of the target mounts
+func (p *policyCheck) check(target []string) []PolicyMountIssue {
+	target = append(append([]string{}, target...), builtinMounts...)
+
+	var issues []PolicyMountIssue
+	for _, name := range p.policies {
+		for _, path := range p.paths[name] {
+			if matchesAnyMount(path, target) {
+				continue
+			}
+			issues = append(issues, PolicyMountIssue{
+				Policy:   name,
+				Path:     path,
+				Mount:    firstSegment(path),
+				InSource: matchesAnyMount(path, p.sources),
+				Filtered: p.filtered,
+			})
+		}
+	}
+	return issues
+}
+
+func (p *policyCheck) empty() bool {
+	return len(p.policies) == 0
+}
+
+// reset starts the check of the next namespace
+func (p *policyCheck) reset() {
+	*p = policyCheck{filtered: p.filtered}
+}
+
+func matchesAnyMount(path string, mounts []string) bool {
+	for _, mount := range mounts {
+		if matchesMount(path, mount) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMount reports whether an ACL path can refer to anything in mount,
+// taking + segments and a trailing * glob into account
+func matchesMount(path, mount string) bool {
+	prefix := strings.TrimSuffix(path, "*")
+	glob := prefix != path
+
+	pathSegments := strings.Split(prefix, "/")
+	mountSegments := strings.Split(strings.TrimSuffix(mount, "/"), "/")
+	for i, segment := range mountSegments {
+		if i >= len(pathSegments) {
+			return false
+		}
+		last := i == len(pathSegments)-1
+
+		switch {
+		case pathSegments[i] == "+" || pathSegments[i] == segment:
+			if last {
+				return glob || i == len(mountSegments)-1
+			}
+		case last && glob && strings.HasPrefix(segment, pathSegments[i]):
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func firstSegment(path string) string {
+	if i := strings.Index(path, "/"); i >= 0 {
+		return path[:i+1]
+	}
+	return path
+}
+
+// listMountPaths returns the secret mounts and auth methods (as auth/<path>)
+// of the current namespace
+func (c *Client) listMountPaths() ([]string, error) {
+	mounts, err := c.client.Sys().ListMounts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mounts: %w", err)
+	}
+	auths, err := c.client.Sys().ListAuth()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list auth methods: %w", err)
+	}
+
+	var paths []string
+	for path := range mounts {
+		paths = append(paths, path)
+	}
+	for path := range auths {
+		paths = append(paths, "auth/"+path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// printPolicyIssues reports policy paths whose mount is missing from the
+// target, listing those that exist in neither cluster separately
+func printPolicyIssues(issues []PolicyMountIssue) {
+	var unknown []PolicyMountIssue
+	for _, issue := range issues {
+		if issue.InSource {
+			fmt.Printf("  Warning: policy %s path %s refers to %s, which is not mounted in the target\n", issue.Policy, issue.Path, issue.Mount)
+		} else {
+			unknown = append(unknown, issue)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return
+	}
+	if unknown[0].Filtered {
+		fmt.Println("  Policies referencing mounts that are neither in the target nor in the backup (it was limited with --engines, so the source may have them):")
+	} else {
+		fmt.Println("  Policies referencing mounts that exist in neither cluster:")
+	}
+	for _, issue := range unknown {
+		fmt.Printf("    - %s: %s\n", issue.Policy, issue.Path)
+	}
+}
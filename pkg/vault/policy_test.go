@@ -0,0 +1,203 @@
+package vault
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  []PolicyPath
+	}{
+		{
+			name: "hcl",
+			rules: `
+path "secret/data/*" {
+  capabilities = ["read", "list"]
+}
+
+path "sys/mounts" {
+  policy = "read"
+}`,
+			want: []PolicyPath{
+				{Path: "secret/data/*", Capabilities: []string{"read", "list"}},
+				{Path: "sys/mounts", Capabilities: []string{"policy=read"}},
+			},
+		},
+		{
+			name:  "json",
+			rules: `{"path": {"kv/+/app": {"capabilities": ["create", "update"]}}}`,
+			want: []PolicyPath{
+				{Path: "kv/+/app", Capabilities: []string{"create", "update"}},
+			},
+		},
+		{
+			name:  "no paths",
+			rules: `# empty policy`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParsePolicy(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(policy.Paths, tt.want) {
+				t.Errorf("Paths = %#v, want %#v", policy.Paths, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	for _, rules := range []string{`path "secret/*" {`, `path { capabilities = ["read"] }`} {
+		if _, err := ParsePolicy(rules); err == nil {
+			t.Errorf("ParsePolicy(%q): expected an error", rules)
+		}
+	}
+}
+
+func TestRewritePolicy(t *testing.T) {
+	rules, err := loadTestRewriteRules(t, testRewriteRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := `path "ats-secret/data/db" {
+  capabilities = ["read"]
+}
+
+path "sys/mounts" {
+  capabilities = ["read"]
+}
+`
+
+	text, parsed, err := rewritePolicy(policy, rules, []string{"ats-secret/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Paths[0].Path != "apps/data/ats/db" || parsed.Paths[1].Path != "sys/mounts" {
+		t.Errorf("unexpected paths %#v", parsed.Paths)
+	}
+	if !strings.Contains(text, `path "apps/data/ats/db"`) || strings.Contains(text, "ats-secret") {
+		t.Errorf("rewritten policy still refers to the source mount:\n%s", text)
+	}
+
+	text, _, err = rewritePolicy(policy, nil, []string{"ats-secret/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != policy {
+		t.Errorf("policy without rules changed:\n%s", text)
+	}
+}
+
+func TestMatchesMount(t *testing.T) {
+	tests := []struct {
+		path  string
+		mount string
+		want  bool
+	}{
+		{"secret/data/app", "secret/", true},
+		{"secret/*", "secret/", true},
+		{"secret", "secret/", true},
+		{"secretive/data", "secret/", false},
+		{"sec*", "secret/", true},
+		{"*", "secret/", true},
+		{"+/data/app", "secret/", true},
+		{"auth/userpass/login/*", "auth/userpass/", true},
+		{"auth/+/login", "auth/userpass/", true},
+		{"auth/*", "auth/userpass/", true},
+		{"auth/ldap/login", "auth/userpass/", false},
+		{"auth/", "auth/userpass/", false},
+		{"team/prod/data/app", "team/prod/", true},
+		{"team/dev/data/app", "team/prod/", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesMount(tt.path, tt.mount); got != tt.want {
+			t.Errorf("matchesMount(%q, %q) = %v, want %v", tt.path, tt.mount, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyCheck(t *testing.T) {
+	policy, err := ParsePolicy(`
+path "secret/data/*" { capabilities = ["read"] }
+path "kv/data/*" { capabilities = ["read"] }
+path "gone/*" { capabilities = ["read"] }
+path "sys/health" { capabilities = ["read"] }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filtered := range []bool{false, true} {
+		check := policyCheck{filtered: filtered}
+		check.addSource("secret/")
+		check.addSource("kv/")
+		check.add("app", policy)
+
+		want := []PolicyMountIssue{
+			{Policy: "app", Path: "kv/data/*", Mount: "kv/", InSource: true, Filtered: filtered},
+			{Policy: "app", Path: "gone/*", Mount: "gone/", Filtered: filtered},
+		}
+		if got := check.check([]string{"secret/"}); !reflect.DeepEqual(got, want) {
+			t.Errorf("filtered=%v: check = %#v, want %#v", filtered, got, want)
+		}
+
+		check.reset()
+		if !check.empty() || check.filtered != filtered {
+			t.Errorf("filtered=%v: reset left %#v", filtered, check)
+		}
+	}
+}
+
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestPrintPolicyIssues(t *testing.T) {
+	for _, filtered := range []bool{false, true} {
+		out := captureStdout(t, func() {
+			printPolicyIssues([]PolicyMountIssue{
+				{Policy: "app", Path: "kv/data/*", Mount: "kv/", InSource: true, Filtered: filtered},
+				{Policy: "app", Path: "gone/*", Mount: "gone/", Filtered: filtered},
+				{Policy: "ops", Path: "old/+", Mount: "old/", Filtered: filtered},
+			})
+		})
+
+		header := "exist in neither cluster"
+		if filtered {
+			header = "limited with --engines"
+		}
+		for _, want := range []string{"policy app path kv/data/* refers to kv/", header, "    - app: gone/*\n", "    - ops: old/+\n"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("filtered=%v: output does not contain %q:\n%s", filtered, want, out)
+			}
+		}
+	}
+
+	if out := captureStdout(t, func() { printPolicyIssues(nil) }); out != "" {
+		t.Errorf("output without issues: %q", out)
+	}
+}
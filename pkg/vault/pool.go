@@ -0,0 +1,109 @@
+package vault
+
+import (
+	"fmt"
+)
+
+// DefaultConcurrency is used when no concurrency is configured
+const DefaultConcurrency = 4
+
+// taskLog buffers the output of a task running on a worker so it can be
+// printed in submission order
+type taskLog struct {
+	lines []string
+}
+
+func (l *taskLog) Printf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *taskLog) flush() {
+	for _, line := range l.lines {
+		fmt.Print(line)
+	}
+	l.lines = nil
+}
+
+type poolResult[T any] struct {
+	value T
+	err   error
+	log   *taskLog
+}
+
+// orderedPool runs tasks on a bounded number of workers and hands their
+// results to emit in the order the tasks were submitted, so output is the
+// same as a sequential run. Task errors are collected and returned by Wait;
+// an error from emit stops the pool.
+type orderedPool[T any] struct {
+	sem     chan struct{}
+	pending []chan poolResult[T]
+	emit    func(T, error) error
+	errs    []error
+}
+
+func newOrderedPool[T any](workers int, emit func(T, error) error) *orderedPool[T] {
+	if workers < 1 {
+		workers = 1
+	}
+	return &orderedPool[T]{
+		sem:  make(chan struct{}, workers),
+		emit: emit,
+	}
+}
+
+// Submit queues a task. Once as many tasks are in flight as there are
+// workers, the oldest result is emitted before the new task starts.
+func (p *orderedPool[T]) Submit(task func(log *taskLog) (T, error)) error {
+	if len(p.pending) >= cap(p.sem) {
+		if err := p.next(); err != nil {
+			return err
+		}
+	}
+
+	done := make(chan poolResult[T], 1)
+	p.pending = append(p.pending, done)
+
+	p.sem <- struct{}{}
+	go func() {
+		defer func() { <-p.sem }()
+		log := &taskLog{}
+		value, err := task(log)
+		done <- poolResult[T]{value: value, err: err, log: log}
+	}()
+	return nil
+}
+
+// Wait emits every outstanding result and returns the errors of the tasks
+// submitted since the last Wait. The error is only set if emit failed.
+func (p *orderedPool[T]) Wait() ([]error, error) {
+	for len(p.pending) > 0 {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	errs := p.errs
+	p.errs = nil
+	return errs, nil
+}
+
+func (p *orderedPool[T]) next() error {
+	result := <-p.pending[0]
+	p.pending = p.pending[1:]
+
+	result.log.flush()
+	if result.err != nil {
+		p.errs = append(p.errs, result.err)
+	}
+	return p.emit(result.value, result.err)
+}
+
+// printFailures summarises the task errors collected by a pool
+func printFailures(what string, errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	fmt.Printf("    Warning: %d %s:\n", len(errs), what)
+	for _, err := range errs {
+		fmt.Printf("      - %v\n", err)
+	}
+}
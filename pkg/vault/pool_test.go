@@ -0,0 +1,105 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOrderedPoolOrder(t *testing.T) {
+	const tasks = 5
+	var emitted []int
+	pool := newOrderedPool(tasks, func(n int, err error) error {
+		emitted = append(emitted, n)
+		return nil
+	})
+
+	// Each task waits for the next one to finish, so they complete in
+	// reverse order
+	gates := make([]chan struct{}, tasks+1)
+	for i := range gates {
+		gates[i] = make(chan struct{})
+	}
+	close(gates[tasks])
+	for i := 0; i < tasks; i++ {
+		i := i
+		err := pool.Submit(func(log *taskLog) (int, error) {
+			<-gates[i+1]
+			defer close(gates[i])
+			if i%2 == 1 {
+				return i, fmt.Errorf("task %d failed", i)
+			}
+			return i, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errs, err := pool.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(emitted, want) {
+		t.Errorf("emitted %v, want %v", emitted, want)
+	}
+	if len(errs) != 2 || errs[0].Error() != "task 1 failed" || errs[1].Error() != "task 3 failed" {
+		t.Errorf("Wait returned errors %v", errs)
+	}
+
+	// Errors are reset by Wait
+	if errs, _ := pool.Wait(); len(errs) != 0 {
+		t.Errorf("second Wait returned errors %v", errs)
+	}
+}
+
+func TestOrderedPoolWorkers(t *testing.T) {
+	var running, peak int32
+	pool := newOrderedPool(2, func(n int, err error) error { return nil })
+	for i := 0; i < 20; i++ {
+		err := pool.Submit(func(log *taskLog) (int, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			return 0, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := pool.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if peak > 2 {
+		t.Errorf("%d tasks ran at once with 2 workers", peak)
+	}
+}
+
+func TestOrderedPoolEmitError(t *testing.T) {
+	stop := errors.New("stop")
+	pool := newOrderedPool(1, func(n int, err error) error {
+		if n == 1 {
+			return stop
+		}
+		return nil
+	})
+
+	var submitErr error
+	for i := 0; i < 3 && submitErr == nil; i++ {
+		i := i
+		submitErr = pool.Submit(func(log *taskLog) (int, error) { return i, nil })
+	}
+	if submitErr == nil {
+		_, submitErr = pool.Wait()
+	}
+	if submitErr != stop {
+		t.Errorf("pool returned %v, want the emit error", submitErr)
+	}
+}
@@ -0,0 +1,135 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"golang.org/x/time/rate"
+)
+
+// RequestOptions control how the client paces and retries its requests
+type RequestOptions struct {
+	// MaxRPS caps the requests per second sent to Vault; 0 means unlimited
+	MaxRPS float64
+
+	// MaxRetries is how often a request failing with 429 or 5xx is retried
+	MaxRetries int
+
+	// RetryBudget caps the retries over the life of the client, so an
+	// unhealthy server fails fast instead of every request backing off
+	RetryBudget int
+
+	MinRetryWait time.Duration
+	MaxRetryWait time.Duration
+}
+
+func DefaultRequestOptions() RequestOptions {
+	return RequestOptions{
+		MaxRetries:   5,
+		RetryBudget:  1000,
+		MinRetryWait: 500 * time.Millisecond,
+		MaxRetryWait: 30 * time.Second,
+	}
+}
+
+// configureRequests sets up rate limiting and retries on the API config.
+// The API client applies them to every request, including Logical() calls.
+func configureRequests(config *api.Config, opts RequestOptions) {
+	if opts.MaxRPS > 0 {
+		burst := int(opts.MaxRPS)
+		if burst < 1 {
+			burst = 1
+		}
+		config.Limiter = rate.NewLimiter(rate.Limit(opts.MaxRPS), burst)
+	}
+
+	config.MaxRetries = opts.MaxRetries
+	config.MinRetryWait = opts.MinRetryWait
+	config.MaxRetryWait = opts.MaxRetryWait
+	config.Backoff = exponentialJitterBackoff
+
+	budget := &retryBudget{remaining: int64(opts.RetryBudget)}
+	config.CheckRetry = budget.checkRetry
+}
+
+type retryBudget struct {
+	remaining int64
+	exhausted sync.Once
+}
+
+// checkRetry retries whatever the API client would retry, plus 429s, as long
+// as the budget lasts
+func (b *retryBudget) checkRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	retry, checkErr := api.DefaultRetryPolicy(ctx, resp, err)
+	if checkErr != nil {
+		return false, checkErr
+	}
+	if !retry && resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		retry = true
+	}
+	if !retry {
+		return false, nil
+	}
+
+	if atomic.AddInt64(&b.remaining, -1) < 0 {
+		b.exhausted.Do(func() {
+			fmt.Println("  Warning: retry budget exhausted; failing requests are no longer retried")
+		})
+		return false, nil
+	}
+	return true, nil
+}
+
+// exponentialJitterBackoff doubles the wait with each attempt and picks a
+// random wait up to that bound. A Retry-After header from Vault takes
+// precedence.
+func exponentialJitterBackoff(min, max time.Duration, attempt int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait := time.Duration(seconds) * time.Second
+			if wait > max {
+				wait = max
+			}
+			return wait
+		}
+	}
+
+	if attempt > 30 {
+		attempt = 30
+	}
+	wait := min << uint(attempt)
+	if wait <= 0 || wait > max {
+		wait = max
+	}
+	if wait <= min {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(wait-min)))
+}
+
+// Failures returns how many secrets and other entries could not be read or
+// written, even after retries, since the client was created
+func (c *Client) Failures() int {
+	return int(atomic.LoadInt64(&c.failures))
+}
+
+// reportFailures prints the errors collected by a worker pool and counts them
+// as hard failures
+func (c *Client) reportFailures(what string, errs []error) {
+	atomic.AddInt64(&c.failures, int64(len(errs)))
+	printFailures(what, errs)
+}
+
+// reportFailure prints a single write that failed after retries and counts
+// it like reportFailures
+func (c *Client) reportFailure(format string, args ...interface{}) {
+	atomic.AddInt64(&c.failures, 1)
+	fmt.Printf(format, args...)
+}
@@ -0,0 +1,91 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestExponentialJitterBackoff(t *testing.T) {
+	min, max := 100*time.Millisecond, 2*time.Second
+	retryAfter := func(status int, value string) *http.Response {
+		return &http.Response{StatusCode: status, Header: http.Header{"Retry-After": []string{value}}}
+	}
+
+	tests := []struct {
+		name     string
+		attempt  int
+		resp     *http.Response
+		low, top time.Duration
+	}{
+		{"first attempt", 0, nil, min, min},
+		{"second attempt", 1, nil, min, 2 * min},
+		{"fourth attempt", 3, nil, min, 8 * min},
+		{"capped at max", 10, nil, min, max},
+		{"no overflow", 100, nil, min, max},
+		{"retry-after on 429", 1, retryAfter(http.StatusTooManyRequests, "1"), time.Second, time.Second},
+		{"retry-after on 503", 1, retryAfter(http.StatusServiceUnavailable, "0"), 0, 0},
+		{"retry-after capped at max", 1, retryAfter(http.StatusTooManyRequests, "60"), max, max},
+		{"retry-after ignored on 500", 0, retryAfter(http.StatusInternalServerError, "60"), min, min},
+		{"invalid retry-after", 1, retryAfter(http.StatusTooManyRequests, "soon"), min, 2 * min},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			if got := exponentialJitterBackoff(min, max, tt.attempt, tt.resp); got < tt.low || got > tt.top {
+				t.Errorf("%s: wait %v not in [%v, %v]", tt.name, got, tt.low, tt.top)
+				break
+			}
+		}
+	}
+}
+
+func TestCheckRetry(t *testing.T) {
+	tests := []struct {
+		status int
+		want   bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusBadRequest, false},
+		{http.StatusForbidden, false},
+		{http.StatusNotFound, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+	}
+
+	budget := &retryBudget{remaining: 100}
+	for _, tt := range tests {
+		retry, err := budget.checkRetry(context.Background(), &http.Response{StatusCode: tt.status}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retry != tt.want {
+			t.Errorf("checkRetry(%d) = %v, want %v", tt.status, retry, tt.want)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	budget := &retryBudget{remaining: 2}
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable}
+
+	for i, want := range []bool{true, true, false, false} {
+		retry, err := budget.checkRetry(context.Background(), resp, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retry != want {
+			t.Errorf("retry %d = %v, want %v", i+1, retry, want)
+		}
+	}
+
+	// Responses that are not retried do not use the budget
+	budget = &retryBudget{remaining: 1}
+	budget.checkRetry(context.Background(), &http.Response{StatusCode: http.StatusNotFound}, nil)
+	if retry, _ := budget.checkRetry(context.Background(), resp, nil); !retry {
+		t.Error("a 404 used up the retry budget")
+	}
+}
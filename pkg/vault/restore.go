@@ -2,282 +2,802 @@ package vault
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/api"
 )
 
-func (c *Client) Restore(backup *BackupData, opts RestoreOptions) error {
-	// Restore secret engines first
-	fmt.Println("\nRestoring secret engines...")
-	if err := c.restoreSecretEngines(backup, opts.Engines); err != nil {
-		return fmt.Errorf("failed to restore secret engines: %w", err)
+func (c *Client) Restore(source BackupSource, opts RestoreOptions) (BackupStats, error) {
+	r := newRestorer(c, opts)
+	if err := source.Replay(r); err != nil {
+		return r.stats, err
 	}
+	r.leaveSection()
+	r.checkPolicies()
+	return r.stats, nil
+}
 
-	// Restore policies
-	if !opts.SkipPolicies {
-		fmt.Println("\nRestoring policies...")
-		if err := c.restorePolicies(backup); err != nil {
-			return fmt.Errorf("failed to restore policies: %w", err)
-		}
+// restorer is a BackupSink that writes each record to Vault as it arrives.
+type restorer struct {
+	c       *Client
+	opts    RestoreOptions
+	stats   BackupStats
+	section string
+
+	// engine is the mount currently receiving secrets, nil when it is
+	// filtered out or could not be created. target is its rewritten path.
+	engine  *SecretEngineBackup
+	target  string
+	handler SecretHandler
+	secrets int
+	failed  bool
+	writes  *orderedPool[string]
+
+	identity *identityRestore
+
+	// root is the target namespace the restore started in, namespaces the
+	// stack of mapped child namespaces currently being restored
+	root       string
+	namespaces []string
+	journal    *Journal
+
+	// mounts are the source engine paths seen in the current namespace,
+	// used to find the mount in policy paths
+	mounts   []string
+	policies policyCheck
+}
+
+func newRestorer(c *Client, opts RestoreOptions) *restorer {
+	return &restorer{
+		c:       c,
+		opts:    opts,
+		root:    c.client.Namespace(),
+		journal: opts.Journal,
+	}
+}
+
+func (r *restorer) enterSection(section string) {
+	if r.section == section {
+		return
 	}
+	r.finishSection()
+	r.section = section
 
-	// Restore auth methods
-	if !opts.SkipAuth {
+	switch section {
+	case recordEngine:
+		fmt.Println("\nRestoring secret engines...")
+	case recordPolicy:
+		fmt.Println("\nRestoring policies...")
+	case recordAuthMethod:
 		fmt.Println("\nRestoring auth methods...")
-		if err := c.restoreAuthMethods(backup, opts); err != nil {
-			return fmt.Errorf("failed to restore auth methods: %w", err)
-		}
+	case recordEntity:
+		fmt.Println("\nRestoring identity entities...")
+	case recordGroup:
+		fmt.Println("\nRestoring identity groups...")
 	}
+}
 
+func (r *restorer) finishSection() {
+	switch r.section {
+	case recordPolicy:
+		fmt.Printf("  Restored %d policies\n", r.stats.Policies)
+	case recordAuthMethod:
+		fmt.Printf("  Restored %d auth methods\n", r.stats.AuthMethods)
+	case recordEntity:
+		fmt.Printf("  Restored %d entities\n", r.stats.Entities)
+	case recordGroup:
+		r.c.restoreGroupMemberships(r.identity)
+		fmt.Printf("  Restored %d groups\n", r.stats.Groups)
+	}
+}
+
+func (r *restorer) WriteHeader(header BackupHeader) error {
+	r.policies.filtered = len(header.Engines) > 0
 	return nil
 }
 
-func (c *Client) restoreSecretEngines(backup *BackupData, filterEngines []string) error {
-	for _, engine := range backup.SecretEngines {
-		// Filter engines if specified
-		if len(filterEngines) > 0 && !contains(filterEngines, strings.TrimSuffix(engine.Path, "/")) {
-			continue
-		}
+func (r *restorer) WriteEngine(engine SecretEngineBackup) error {
+	r.enterSection(recordEngine)
+	r.engine = nil
+	r.secrets = 0
+	r.mounts = append(r.mounts, engine.Path)
+	r.policies.addSource(engine.Path)
+	r.policies.addSource(r.opts.Rewrite.Mount(engine.Path))
+
+	// Filter engines if specified
+	if len(r.opts.Engines) > 0 && !contains(r.opts.Engines, strings.TrimSuffix(engine.Path, "/")) {
+		return nil
+	}
+
+	if r.opts.Journal.Done(journalKey(recordEngine, engine.Path)) {
+		fmt.Printf("  Skipping engine: %s (already restored)\n", engine.Path)
+		return nil
+	}
 
+	target := engine
+	target.Path = r.opts.Rewrite.Mount(engine.Path)
+	if target.Path != engine.Path {
+		fmt.Printf("  Restoring engine: %s as %s (type: %s)\n", engine.Path, target.Path, engine.Type)
+	} else {
 		fmt.Printf("  Restoring engine: %s (type: %s)\n", engine.Path, engine.Type)
+	}
+	r.failed = false
 
-		// Check if mount exists
-		mounts, err := c.client.Sys().ListMounts()
-		if err != nil {
-			return err
-		}
+	if err := r.c.restoreSecretEngine(target); err != nil {
+		r.c.reportFailure("    Warning: failed to create mount %s: %v\n", target.Path, err)
+		return nil
+	}
 
-		mountExists := false
-		for path := range mounts {
-			if path == engine.Path {
-				mountExists = true
-				break
+	handler := engineHandler(engine.Type)
+	if handler != nil {
+		if err := handler.Restore(r.c, target.Path, engine, r.opts); err != nil {
+			// Left open in the journal so a resumed run retries it
+			r.failed = true
+			if _, ok := err.(entriesFailed); ok {
+				fmt.Printf("    Warning: %s: %v\n", target.Path, err)
+			} else {
+				r.c.reportFailure("    Warning: %s: %v\n", target.Path, err)
 			}
 		}
+	}
 
-		// Create mount if it doesn't exist
-		if !mountExists {
-			mountInput := &api.MountInput{
-				Type:        engine.Type,
-				Description: engine.Description,
-				Config:      api.MountConfigInput{},
-				Options:     convertInterfaceMapToString(engine.Options),
-			}
+	r.engine = &engine
+	r.target = target.Path
+	r.handler, _ = handler.(SecretHandler)
+	r.stats.SecretEngines++
 
-			if err := c.client.Sys().Mount(strings.TrimSuffix(engine.Path, "/"), mountInput); err != nil {
-				fmt.Printf("    Warning: failed to create mount %s: %v\n", engine.Path, err)
-				continue
-			}
+	// Secrets are written concurrently; results are handled in backup order
+	r.writes = newOrderedPool(r.opts.Concurrency, func(key string, err error) error {
+		r.secrets++
+		r.stats.Secrets++
+		if err != nil {
+			r.failed = true
+			return nil
 		}
+		return r.opts.Journal.Record(key)
+	})
+	return nil
+}
 
-		// Restore secrets
-		if engine.Type == "kv" || engine.Type == "generic" {
-			version := 1
-			if engine.Options != nil && engine.Options["version"] == "2" {
-				version = 2
-			}
+func (r *restorer) WriteSecret(enginePath string, secret SecretBackup) error {
+	if r.engine == nil || r.engine.Path != enginePath {
+		return nil
+	}
 
-			if version == 2 {
-				if err := c.restoreKVv2Secrets(engine.Path, engine.Secrets); err != nil {
-					fmt.Printf("    Warning: failed to restore secrets to %s: %v\n", engine.Path, err)
-				} else {
-					fmt.Printf("    Restored %d secrets\n", len(engine.Secrets))
-				}
-			} else {
-				if err := c.restoreKVv1Secrets(engine.Path, engine.Secrets); err != nil {
-					fmt.Printf("    Warning: failed to restore secrets to %s: %v\n", engine.Path, err)
-				} else {
-					fmt.Printf("    Restored %d secrets\n", len(engine.Secrets))
-				}
-			}
-		}
+	key := journalKey(recordSecret, enginePath+secret.Path)
+	if r.opts.Journal.Done(key) {
+		return nil
+	}
+
+	if r.handler == nil {
+		return nil
+	}
+
+	mountPath, engine, opts, handler := r.target, *r.engine, r.opts, r.handler
+	secret.Path = r.opts.Rewrite.Secret(enginePath, secret.Path)
+	return r.writes.Submit(func(log *taskLog) (string, error) {
+		return key, handler.RestoreSecret(r.c, mountPath, engine, secret, opts, log)
+	})
+}
+
+func (r *restorer) EndEngine(enginePath string) error {
+	if r.engine == nil {
+		return nil
+	}
+	failures, err := r.writes.Wait()
+	if err != nil {
+		return err
+	}
+	r.c.reportFailures("secrets were not fully restored", failures)
+	if r.handler != nil {
+		fmt.Printf("    Restored %d secrets\n", r.secrets)
+	}
+	r.engine = nil
+
+	// An engine with failed secrets is left open so a resumed run retries them
+	if r.failed {
+		return nil
+	}
+	return r.opts.Journal.Record(journalKey(recordEngine, enginePath))
+}
+
+func (r *restorer) WritePolicy(policy PolicyBackup) error {
+	if r.opts.SkipPolicies {
+		return nil
+	}
+	r.enterSection(recordPolicy)
+
+	key := journalKey(recordPolicy, policy.Name)
+	if r.opts.Journal.Done(key) {
+		return nil
+	}
+
+	rules, parsed, err := rewritePolicy(policy.Policy, r.opts.Rewrite, r.mounts)
+	if err != nil {
+		fmt.Printf("  Warning: policy %s restored unchanged: %v\n", policy.Name, err)
+	}
+	if parsed != nil {
+		r.policies.add(policy.Name, parsed)
+	}
+
+	if err := r.c.client.Sys().PutPolicy(policy.Name, rules); err != nil {
+		r.c.reportFailure("  Warning: failed to restore policy %s: %v\n", policy.Name, err)
+		return nil
+	}
+
+	r.stats.Policies++
+	return r.opts.Journal.Record(key)
+}
+
+func (r *restorer) WriteAuthMethod(auth AuthMethodBackup) error {
+	r.policies.addSource("auth/" + auth.Path)
+	if r.opts.SkipAuth {
+		return nil
+	}
+	r.enterSection(recordAuthMethod)
+
+	if err := r.c.restoreAuthMethod(auth, r.opts); err != nil {
+		r.c.reportFailure("    Warning: %v\n", err)
+		return nil
 	}
 
+	r.stats.AuthMethods++
 	return nil
 }
 
-func (c *Client) restoreKVv2Secrets(mountPath string, secrets []SecretBackup) error {
-	for _, secret := range secrets {
-		// Restore versions in order
-		for _, version := range secret.Versions {
-			if version.Destroyed {
-				continue // Skip destroyed versions
-			}
+func (r *restorer) WriteEntity(entity EntityBackup) error {
+	if r.opts.SkipIdentity {
+		return nil
+	}
+	r.enterSection(recordEntity)
 
-			dataPath := mountPath + "data/" + secret.Path
-			data := map[string]interface{}{
-				"data": version.Data,
-			}
+	if err := r.loadIdentityState(); err != nil {
+		return err
+	}
 
-			// Don't use CAS for version control during restore - just write sequentially
-			// The versions will be created in order automatically
+	key := journalKey(recordEntity, entity.Name)
+	if r.opts.Journal.Done(key) {
+		// Still needed to remap group members
+		return r.c.resolveEntity(r.identity, entity)
+	}
 
-			_, err := c.client.Logical().Write(dataPath, data)
-			if err != nil {
-				fmt.Printf("      Warning: failed to restore %s version %d: %v\n", secret.Path, version.Version, err)
-			}
-		}
+	if err := r.c.restoreEntity(r.identity, entity); err != nil {
+		r.c.reportFailure("  Warning: failed to restore entity %s: %v\n", entity.Name, err)
+		return nil
+	}
 
-		// Update metadata if needed
-		if secret.Metadata.MaxVersions > 0 || secret.Metadata.CasRequired || len(secret.Metadata.CustomMetadata) > 0 {
-			metadataPath := mountPath + "metadata/" + secret.Path
-			metadataData := map[string]interface{}{}
+	r.stats.Entities++
+	return r.opts.Journal.Record(key)
+}
 
-			if secret.Metadata.MaxVersions > 0 {
-				metadataData["max_versions"] = secret.Metadata.MaxVersions
-			}
-			if secret.Metadata.CasRequired {
-				metadataData["cas_required"] = true
-			}
-			if len(secret.Metadata.CustomMetadata) > 0 {
-				metadataData["custom_metadata"] = secret.Metadata.CustomMetadata
-			}
-			if secret.Metadata.DeleteVersionAfter != "" {
-				metadataData["delete_version_after"] = secret.Metadata.DeleteVersionAfter
-			}
+func (r *restorer) WriteGroup(group GroupBackup) error {
+	if r.opts.SkipIdentity {
+		return nil
+	}
+	r.enterSection(recordGroup)
 
-			if len(metadataData) > 0 {
-				_, err := c.client.Logical().Write(metadataPath, metadataData)
-				if err != nil {
-					fmt.Printf("      Warning: failed to update metadata for %s: %v\n", secret.Path, err)
-				}
-			}
-		}
+	if err := r.loadIdentityState(); err != nil {
+		return err
+	}
+
+	key := journalKey(recordGroup, group.Name)
+	if r.opts.Journal.Done(key) {
+		return r.c.resolveGroup(r.identity, group)
+	}
+
+	if err := r.c.restoreGroup(r.identity, group); err != nil {
+		r.c.reportFailure("  Warning: failed to restore group %s: %v\n", group.Name, err)
+		return nil
+	}
+
+	r.stats.Groups++
+	return r.opts.Journal.Record(key)
+}
+
+func (r *restorer) BeginNamespace(namespace NamespaceBackup) error {
+	r.leaveSection()
+	r.checkPolicies()
+
+	target := mapNamespace(namespace.Path, r.opts.NamespaceMap)
+	if target != namespace.Path {
+		fmt.Printf("\nRestoring namespace %s as %s...\n", namespace.Path, target)
+	} else {
+		fmt.Printf("\nRestoring namespace %s...\n", namespace.Path)
+	}
+
+	if err := r.c.ensureNamespace(r.root, target, namespace.CustomMetadata); err != nil {
+		return err
 	}
 
+	r.namespaces = append(r.namespaces, target)
+	r.enterNamespace(target)
+	r.stats.Namespaces++
 	return nil
 }
 
-func (c *Client) restoreKVv1Secrets(mountPath string, secrets []SecretBackup) error {
-	for _, secret := range secrets {
-		if len(secret.Versions) == 0 {
+func (r *restorer) EndNamespace(path string) error {
+	if len(r.namespaces) == 0 {
+		return fmt.Errorf("unexpected end of namespace %s", path)
+	}
+	r.leaveSection()
+	r.checkPolicies()
+
+	r.namespaces = r.namespaces[:len(r.namespaces)-1]
+	parent := ""
+	if len(r.namespaces) > 0 {
+		parent = r.namespaces[len(r.namespaces)-1]
+	}
+	r.enterNamespace(parent)
+	return nil
+}
+
+// enterNamespace points the client and journal at a namespace relative to
+// the restore root. Identity state is per namespace and is reloaded.
+func (r *restorer) enterNamespace(path string) {
+	r.c.SetNamespace(joinNamespace(r.root, path))
+	r.opts.Journal = r.journal.Scoped(path)
+	r.identity = nil
+	r.mounts = nil
+}
+
+// leaveSection finishes the current section so the next namespace starts
+// with fresh section headers
+func (r *restorer) leaveSection() {
+	r.finishSection()
+	r.section = ""
+}
+
+// checkPolicies checks the paths of the policies restored in the current
+// namespace against its mounts, once engines and auth methods are in place
+func (r *restorer) checkPolicies() {
+	if r.policies.empty() {
+		r.policies.reset()
+		return
+	}
+
+	fmt.Println("\nChecking policy paths against target mounts...")
+	target, err := r.c.listMountPaths()
+	if err != nil {
+		fmt.Printf("  Warning: %v\n", err)
+	} else if issues := r.policies.check(target); len(issues) > 0 {
+		printPolicyIssues(issues)
+	} else {
+		fmt.Println("  All policy paths refer to mounted paths")
+	}
+	r.policies.reset()
+}
+
+// loadIdentityState reads the target auth accessors once auth methods have
+// been restored, so aliases can be remapped to them
+func (r *restorer) loadIdentityState() error {
+	if r.identity != nil {
+		return nil
+	}
+
+	state, err := r.c.newIdentityRestore()
+	if err != nil {
+		return fmt.Errorf("failed to list target auth methods: %w", err)
+	}
+	r.identity = state
+	return nil
+}
+
+// kvVersion returns the KV version of a kv/generic mount, or 0 for other types
+func kvVersion(engine SecretEngineBackup) int {
+	if engine.Type != "kv" && engine.Type != "generic" {
+		return 0
+	}
+	if engine.Options != nil && engine.Options["version"] == "2" {
+		return 2
+	}
+	return 1
+}
+
+func (c *Client) restoreSecretEngine(engine SecretEngineBackup) error {
+	// Check if mount exists
+	mounts, err := c.client.Sys().ListMounts()
+	if err != nil {
+		return err
+	}
+
+	// Re-tune an existing mount to match the backed-up config
+	if existing, ok := mounts[engine.Path]; ok {
+		if err := c.tuneMount(strings.TrimSuffix(engine.Path, "/"), existing.Config, engine.Config); err != nil {
+			c.reportFailure("    Warning: failed to tune mount %s: %v\n", engine.Path, err)
+		}
+		return nil
+	}
+
+	// Create mount if it doesn't exist
+	mountInput := &api.MountInput{
+		Type:        engine.Type,
+		Description: engine.Description,
+		Config:      mountConfigInput(engine.Config),
+		Options:     convertInterfaceMapToString(engine.Options),
+	}
+
+	return c.client.Sys().Mount(strings.TrimSuffix(engine.Path, "/"), mountInput)
+}
+
+// kvPlaceholder is written for versions whose data is not in the backup, so
+// that version numbers in the target line up with the source.
+var kvPlaceholder = map[string]interface{}{
+	"vault-migrator": "placeholder",
+}
+
+// restoreKVv2Secret writes every version of a secret. The version the target
+// was at before the first write is journaled, so a resumed run does not write
+// again the versions an interrupted run already added.
+func (c *Client) restoreKVv2Secret(mountPath string, secret SecretBackup, journal *Journal, log Logger) error {
+	dataPath := mountPath + "data/" + secret.Path
+	metadataPath := mountPath + "metadata/" + secret.Path
+
+	versions := make(map[int]SecretVersion)
+	last := secret.Metadata.CurrentVersion
+	for _, version := range secret.Versions {
+		versions[version.Version] = version
+		if version.Version > last {
+			last = version.Version
+		}
+	}
+
+	// Versions are appended, so an existing secret shifts the numbering by
+	// offset
+	current := 0
+	if existing, err := c.client.Logical().Read(metadataPath); err == nil && existing != nil && existing.Data != nil {
+		current = parseMetadata(existing.Data).CurrentVersion
+	}
+	startKey := journalKey("kv start", mountPath+secret.Path)
+	written, offset := 0, 0
+	if start, ok := journal.Value(startKey); ok {
+		offset, _ = strconv.Atoi(start)
+		written = current - offset
+		switch {
+		case written < 0:
+			offset, written = current, 0
+		case written > last:
+			written = last
+		}
+		if written > 0 {
+			log.Printf("      Resuming %s after version %d\n", secret.Path, offset+written)
+		}
+	} else {
+		switch {
+		case current == 0:
+		case current == secret.Metadata.CurrentVersion && c.kvLatestMatches(dataPath, secret):
+			log.Printf("      Skipping %s (identical in the target)\n", secret.Path)
+			return nil
+		default:
+			log.Printf("      Warning: %s already has %d versions in the target; version numbers will be offset\n", secret.Path, current)
+			offset = current
+		}
+		if err := journal.RecordValue(startKey, strconv.Itoa(offset)); err != nil {
+			return err
+		}
+	}
+
+	// Apply max_versions and delete_version_after before writing so pruning
+	// behaves as it did in the source. cas_required is set last, otherwise
+	// the writes below would need a check-and-set value.
+	failed := 0
+	if !c.restoreKVv2Metadata(mountPath, secret.Path, secret.Metadata, false, log) {
+		failed++
+	}
+
+	// Write every version in order, then delete/destroy the ones that were
+	// not live in the source
+	var deleted, destroyed []int
+	for n := 1; n <= last; n++ {
+		version, ok := versions[n]
+		data := version.Data
+
+		switch {
+		case !ok || version.Destroyed:
+			destroyed = append(destroyed, n+offset)
+			data = kvPlaceholder
+		case data == nil || isDeletedVersion(version):
+			deleted = append(deleted, n+offset)
+			if data == nil {
+				data = kvPlaceholder
+			}
+		}
+
+		if n <= written {
 			continue
 		}
 
-		// KV v1 only has one version
-		version := secret.Versions[len(secret.Versions)-1]
-		secretPath := mountPath + secret.Path
+		if err := c.writeKVv2Version(dataPath, metadataPath, data, offset+n-1); err != nil {
+			// Later versions would be misnumbered; a resumed run continues
+			// after the ones written so far
+			log.Printf("      Warning: failed to restore %s version %d: %v\n", secret.Path, n, err)
+			return fmt.Errorf("%d writes failed for %s", failed+1, secret.Path)
+		}
+	}
+
+	if len(deleted) > 0 {
+		_, err := c.client.Logical().Write(mountPath+"delete/"+secret.Path, map[string]interface{}{
+			"versions": deleted,
+		})
+		if err != nil {
+			log.Printf("      Warning: failed to delete versions %v of %s: %v\n", deleted, secret.Path, err)
+			failed++
+		}
+	}
 
-		_, err := c.client.Logical().Write(secretPath, version.Data)
+	if len(destroyed) > 0 {
+		_, err := c.client.Logical().Write(mountPath+"destroy/"+secret.Path, map[string]interface{}{
+			"versions": destroyed,
+		})
 		if err != nil {
-			fmt.Printf("      Warning: failed to restore %s: %v\n", secret.Path, err)
+			log.Printf("      Warning: failed to destroy versions %v of %s: %v\n", destroyed, secret.Path, err)
+			failed++
 		}
 	}
 
+	if secret.Metadata.CasRequired && !c.restoreKVv2Metadata(mountPath, secret.Path, secret.Metadata, true, log) {
+		failed++
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d writes failed for %s", failed, secret.Path)
+	}
 	return nil
 }
 
-func (c *Client) restorePolicies(backup *BackupData) error {
-	for _, policy := range backup.Policies {
-		if err := c.client.Sys().PutPolicy(policy.Name, policy.Policy); err != nil {
-			fmt.Printf("  Warning: failed to restore policy %s: %v\n", policy.Name, err)
-			continue
+// writeKVv2Version adds the version after cas. Writes are retried on 5xx, so
+// one that went through but lost its response would add a duplicate version;
+// the check-and-set value makes the retry fail instead, and the write counts
+// as done if the target is now at the next version.
+func (c *Client) writeKVv2Version(dataPath, metadataPath string, data map[string]interface{}, cas int) error {
+	_, err := c.client.Logical().Write(dataPath, map[string]interface{}{
+		"data":    data,
+		"options": map[string]interface{}{"cas": cas},
+	})
+	if err == nil {
+		return nil
+	}
+	if existing, readErr := c.client.Logical().Read(metadataPath); readErr == nil && existing != nil && existing.Data != nil {
+		if parseMetadata(existing.Data).CurrentVersion == cas+1 {
+			return nil
 		}
 	}
+	return err
+}
 
-	fmt.Printf("  Restored %d policies\n", len(backup.Policies))
-	return nil
+// kvLatestMatches reports whether the current version in the target holds the
+// latest live data of the backup, which restore --plan reports as identical
+func (c *Client) kvLatestMatches(dataPath string, secret SecretBackup) bool {
+	latest := latestVersion(secret)
+	if latest == nil {
+		return false
+	}
+	resp, err := c.client.Logical().Read(dataPath)
+	if err != nil || resp == nil || resp.Data == nil {
+		return false
+	}
+	return equalData(resp.Data["data"], latest.Data)
 }
 
-func (c *Client) restoreAuthMethods(backup *BackupData, opts RestoreOptions) error {
-	for _, auth := range backup.AuthMethods {
-		fmt.Printf("  Restoring auth method: %s (type: %s)\n", auth.Path, auth.Type)
+// restoreKVv2Metadata reports whether the metadata was written
+func (c *Client) restoreKVv2Metadata(mountPath, secretPath string, metadata SecretMetadata, casRequired bool, log Logger) bool {
+	metadataPath := mountPath + "metadata/" + secretPath
+	metadataData := map[string]interface{}{}
 
-		// Check if auth method exists
-		auths, err := c.client.Sys().ListAuth()
+	if metadata.MaxVersions > 0 {
+		metadataData["max_versions"] = metadata.MaxVersions
+	}
+	if casRequired {
+		metadataData["cas_required"] = true
+	}
+	if len(metadata.CustomMetadata) > 0 {
+		metadataData["custom_metadata"] = metadata.CustomMetadata
+	}
+	if metadata.DeleteVersionAfter != "" {
+		metadataData["delete_version_after"] = metadata.DeleteVersionAfter
+	}
+
+	if len(metadataData) > 0 {
+		_, err := c.client.Logical().Write(metadataPath, metadataData)
 		if err != nil {
-			return err
+			log.Printf("      Warning: failed to update metadata for %s: %v\n", secretPath, err)
+			return false
 		}
+	}
+	return true
+}
 
-		authExists := false
-		for path := range auths {
-			if path == auth.Path {
-				authExists = true
-				break
-			}
-		}
+// isDeletedVersion reports whether a version was soft-deleted in the source
+func isDeletedVersion(version SecretVersion) bool {
+	if version.DeletionTime == "" {
+		return false
+	}
+	deletedAt, err := time.Parse(time.RFC3339, version.DeletionTime)
+	return err == nil && deletedAt.Before(time.Now())
+}
 
-		// Enable auth method if it doesn't exist
-		if !authExists {
-			enableInput := &api.EnableAuthOptions{
-				Type:        auth.Type,
-				Description: auth.Description,
-				Config:      api.AuthConfigInput{},
-				Options:     convertInterfaceMapToString(auth.Options),
-			}
+func (c *Client) restoreKVv1Secret(mountPath string, secret SecretBackup, log Logger) error {
+	if len(secret.Versions) == 0 {
+		return nil
+	}
 
-			if err := c.client.Sys().EnableAuthWithOptions(strings.TrimSuffix(auth.Path, "/"), enableInput); err != nil {
-				fmt.Printf("    Warning: failed to enable auth method %s: %v\n", auth.Path, err)
-				continue
-			}
+	// KV v1 only has one version
+	version := secret.Versions[len(secret.Versions)-1]
+	secretPath := mountPath + secret.Path
+
+	_, err := c.client.Logical().Write(secretPath, version.Data)
+	if err != nil {
+		log.Printf("      Warning: failed to restore %s: %v\n", secret.Path, err)
+		return fmt.Errorf("failed to restore %s: %w", secret.Path, err)
+	}
+	return nil
+}
+
+func (c *Client) restoreAuthMethod(auth AuthMethodBackup, opts RestoreOptions) error {
+	fmt.Printf("  Restoring auth method: %s (type: %s)\n", auth.Path, auth.Type)
+
+	// Check if auth method exists
+	auths, err := c.client.Sys().ListAuth()
+	if err != nil {
+		return err
+	}
+
+	// Enable auth method if it doesn't exist, otherwise re-tune it
+	if existing, ok := auths[auth.Path]; ok {
+		if err := c.tuneMount("auth/"+strings.TrimSuffix(auth.Path, "/"), existing.Config, auth.Config); err != nil {
+			c.reportFailure("    Warning: failed to tune auth method %s: %v\n", auth.Path, err)
+		}
+	} else {
+		enableInput := &api.EnableAuthOptions{
+			Type:        auth.Type,
+			Description: auth.Description,
+			Config:      mountConfigInput(auth.Config),
+			Options:     convertInterfaceMapToString(auth.Options),
 		}
 
-		// Restore roles and users
-		switch auth.Type {
-		case "userpass":
-			if err := c.restoreUserpassUsers(auth.Path, auth.Users, opts.DefaultPassword); err != nil {
-				fmt.Printf("    Warning: failed to restore userpass users: %v\n", err)
-			}
-		case "approle":
-			if err := c.restoreAppRoles(auth.Path, auth.Roles); err != nil {
-				fmt.Printf("    Warning: failed to restore approles: %v\n", err)
-			}
-		case "ldap":
-			if err := c.restoreLDAPUsers(auth.Path, auth.Users); err != nil {
-				fmt.Printf("    Warning: failed to restore LDAP users: %v\n", err)
-			}
+		if err := c.client.Sys().EnableAuthWithOptions(strings.TrimSuffix(auth.Path, "/"), enableInput); err != nil {
+			return fmt.Errorf("failed to enable auth method %s: %w", auth.Path, err)
+		}
+	}
+
+	// Restore roles and users
+	if handler := authHandler(auth.Type); handler != nil {
+		if err := handler.Restore(c, auth, opts); err != nil {
+			c.reportFailure("    Warning: %v\n", err)
 		}
 	}
 
-	fmt.Printf("  Restored %d auth methods\n", len(backup.AuthMethods))
 	return nil
 }
 
-func (c *Client) restoreUserpassUsers(authPath string, users []UserBackup, defaultPassword string) error {
+func (c *Client) restoreUserpassUsers(authPath string, users []UserBackup, opts RestoreOptions) error {
 	basePath := "auth/" + strings.TrimSuffix(authPath, "/") + "/users"
-	
+	journal := opts.Journal
+
+	// Hashes are written to the storage of the target mount
+	var mountUUID string
+	if opts.PasswordMode == PasswordHash {
+		uuid, err := c.authMountUUID(authPath)
+		if err == nil {
+			err = c.checkRawStorage()
+		}
+		if err != nil {
+			fmt.Printf("      Warning: password hashes cannot be copied, generating passwords instead: %v\n", err)
+		} else {
+			mountUUID = uuid
+		}
+	}
+
+	hashes := 0
 	for _, user := range users {
 		userPath := basePath + "/" + user.Name
+		if journal.Done(journalKey("user", userPath)) {
+			continue
+		}
+
+		password := opts.DefaultPassword
+		if opts.PasswordMode == PasswordRandom || opts.PasswordMode == PasswordHash {
+			generated, err := c.generatePassword(opts.Generator)
+			if err != nil {
+				return err
+			}
+			password = generated
+		}
 		
-		// Add default password to user data
+		// Add the password to user data
 		userData := make(map[string]interface{})
 		for k, v := range user.Data {
 			userData[k] = v
 		}
-		userData["password"] = defaultPassword
+		userData["password"] = password
 		
 		_, err := c.client.Logical().Write(userPath, userData)
 		if err != nil {
-			fmt.Printf("      Warning: failed to restore user %s: %v\n", user.Name, err)
+			c.reportFailure("      Warning: failed to restore user %s: %v\n", user.Name, err)
+			continue
+		}
+
+		copied := false
+		if mountUUID != "" && user.PasswordHash != "" {
+			if err := c.restoreUserpassHash(mountUUID, user.Name, user.PasswordHash); err != nil {
+				fmt.Printf("      Warning: failed to copy password hash of %s, using a generated password: %v\n", user.Name, err)
+			} else {
+				copied = true
+				hashes++
+			}
+		}
+		if !copied && opts.PasswordMode != "" && opts.PasswordMode != PasswordDefault {
+			opts.Credentials.Add(authPath, user.Name, password)
+		}
+
+		if err := journal.Record(journalKey("user", userPath)); err != nil {
+			return err
 		}
 	}
 
+	if hashes > 0 {
+		fmt.Printf("      Copied %d password hashes\n", hashes)
+	}
 	return nil
 }
 
-func (c *Client) restoreAppRoles(authPath string, roles []RoleBackup) error {
+func (c *Client) restoreAppRoles(authPath string, roles []RoleBackup, journal *Journal) error {
 	basePath := "auth/" + strings.TrimSuffix(authPath, "/") + "/role"
 	
 	for _, role := range roles {
 		rolePath := basePath + "/" + role.Name
+		if journal.Done(journalKey("role", rolePath)) {
+			continue
+		}
 		_, err := c.client.Logical().Write(rolePath, role.Data)
 		if err != nil {
-			fmt.Printf("      Warning: failed to restore role %s: %v\n", role.Name, err)
+			c.reportFailure("      Warning: failed to restore role %s: %v\n", role.Name, err)
+			continue
+		}
+		if err := journal.Record(journalKey("role", rolePath)); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func (c *Client) restoreLDAPUsers(authPath string, users []UserBackup) error {
+func (c *Client) restoreLDAPUsers(authPath string, users []UserBackup, journal *Journal) error {
 	basePath := "auth/" + strings.TrimSuffix(authPath, "/") + "/users"
 	
 	for _, user := range users {
 		userPath := basePath + "/" + user.Name
+		if journal.Done(journalKey("user", userPath)) {
+			continue
+		}
 		_, err := c.client.Logical().Write(userPath, user.Data)
 		if err != nil {
-			fmt.Printf("      Warning: failed to restore user %s: %v\n", user.Name, err)
+			c.reportFailure("      Warning: failed to restore user %s: %v\n", user.Name, err)
+			continue
+		}
+		if err := journal.Record(journalKey("user", userPath)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) restoreLDAPGroups(authPath string, groups []RoleBackup, journal *Journal) error {
+	basePath := "auth/" + strings.TrimSuffix(authPath, "/") + "/groups"
+
+	for _, group := range groups {
+		groupPath := basePath + "/" + group.Name
+		if journal.Done(journalKey("group", groupPath)) {
+			continue
+		}
+		if _, err := c.client.Logical().Write(groupPath, group.Data); err != nil {
+			c.reportFailure("      Warning: failed to restore group %s: %v\n", group.Name, err)
+			continue
+		}
+		if err := journal.Record(journalKey("group", groupPath)); err != nil {
+			return err
 		}
 	}
 
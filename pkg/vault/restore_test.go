@@ -0,0 +1,202 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKV serves the KV v2 endpoints of a single mount at secret/
+type fakeKV struct {
+	mu        sync.Mutex
+	versions  map[string][]interface{}
+	deleted   map[string][]int
+	destroyed map[string][]int
+
+	// failAfterWrite makes the next data write succeed but answer 500,
+	// as when the response to a write that went through is lost
+	failAfterWrite bool
+}
+
+func newFakeKV(t *testing.T) (*fakeKV, *Client) {
+	t.Helper()
+	kv := &fakeKV{
+		versions:  make(map[string][]interface{}),
+		deleted:   make(map[string][]int),
+		destroyed: make(map[string][]int),
+	}
+	server := httptest.NewServer(kv)
+	t.Cleanup(server.Close)
+
+	opts := DefaultRequestOptions()
+	opts.MinRetryWait = time.Millisecond
+	opts.MaxRetryWait = 10 * time.Millisecond
+	client, err := NewClientWithOptions(server.URL, "test-token", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kv, client
+}
+
+func (kv *fakeKV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/secret/")
+	i := strings.Index(path, "/")
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	op, name := path[:i], path[i+1:]
+
+	var body map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	switch {
+	case op == "metadata" && r.Method == http.MethodGet:
+		if _, ok := kv.versions[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{"current_version": len(kv.versions[name])},
+		})
+	case op == "metadata":
+		if _, ok := kv.versions[name]; !ok {
+			kv.versions[name] = nil
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case op == "data" && r.Method == http.MethodGet:
+		versions := kv.versions[name]
+		if len(versions) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{"data": versions[len(versions)-1]},
+		})
+	case op == "data":
+		if options, ok := body["options"].(map[string]interface{}); ok {
+			if cas, ok := options["cas"].(float64); ok && int(cas) != len(kv.versions[name]) {
+				writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+					"errors": []string{"check-and-set parameter did not match the current version"},
+				})
+				return
+			}
+		}
+		kv.versions[name] = append(kv.versions[name], body["data"])
+		if kv.failAfterWrite {
+			kv.failAfterWrite = false
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"data": map[string]interface{}{"version": len(kv.versions[name])},
+		})
+	case op == "delete" || op == "destroy":
+		var versions []int
+		for _, v := range body["versions"].([]interface{}) {
+			versions = append(versions, int(v.(float64)))
+		}
+		if op == "delete" {
+			kv.deleted[name] = append(kv.deleted[name], versions...)
+		} else {
+			kv.destroyed[name] = append(kv.destroyed[name], versions...)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func testSecret() SecretBackup {
+	return SecretBackup{
+		Path:     "app",
+		Metadata: SecretMetadata{CurrentVersion: 3},
+		Versions: []SecretVersion{
+			{Version: 1, Data: map[string]interface{}{"v": "1"}},
+			{Version: 2, Data: map[string]interface{}{"v": "2"}, DeletionTime: "2020-01-01T00:00:00Z"},
+			{Version: 3, Data: map[string]interface{}{"v": "3"}},
+		},
+	}
+}
+
+func TestRestoreKVv2SecretResume(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing versions in the target and the start version journaled by
+		// an interrupted run, if any
+		existing int
+		start    string
+		resume   bool
+
+		wantVersions int
+		wantDeleted  []int
+	}{
+		{name: "new secret", wantVersions: 3, wantDeleted: []int{2}},
+		{name: "existing versions are offset", existing: 2, wantVersions: 5, wantDeleted: []int{4}},
+		{name: "existing versions are offset on resume", existing: 2, resume: true, wantVersions: 5, wantDeleted: []int{4}},
+		{name: "resume after one version", existing: 1, start: "0", resume: true, wantVersions: 3, wantDeleted: []int{2}},
+		{name: "resume after offset versions", existing: 3, start: "2", resume: true, wantVersions: 5, wantDeleted: []int{4}},
+		{name: "resume after all versions", existing: 3, start: "0", resume: true, wantVersions: 3, wantDeleted: []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kv, client := newFakeKV(t)
+			for i := 0; i < tt.existing; i++ {
+				kv.versions["app"] = append(kv.versions["app"], map[string]interface{}{"existing": i})
+			}
+
+			path := filepath.Join(t.TempDir(), "journal")
+			if tt.start != "" {
+				journal := openTestJournal(t, path, false)
+				if err := journal.RecordValue(journalKey("kv start", "secret/app"), tt.start); err != nil {
+					t.Fatal(err)
+				}
+				journal.Close()
+			}
+			journal := openTestJournal(t, path, tt.resume)
+
+			if err := client.restoreKVv2Secret("secret/", testSecret(), journal, &taskLog{}); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(kv.versions["app"]); got != tt.wantVersions {
+				t.Errorf("target has %d versions, want %d", got, tt.wantVersions)
+			}
+			if !reflect.DeepEqual(kv.deleted["app"], tt.wantDeleted) {
+				t.Errorf("deleted versions %v, want %v", kv.deleted["app"], tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestRestoreKVv2SecretLostResponse(t *testing.T) {
+	kv, client := newFakeKV(t)
+	kv.failAfterWrite = true
+
+	if err := client.restoreKVv2Secret("secret/", testSecret(), nil, &taskLog{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(kv.versions["app"]); got != 3 {
+		t.Errorf("target has %d versions after a retried write, want 3", got)
+	}
+	if !reflect.DeepEqual(kv.deleted["app"], []int{2}) {
+		t.Errorf("deleted versions %v, want [2]", kv.deleted["app"])
+	}
+}
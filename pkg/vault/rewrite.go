@@ -0,0 +1,214 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// RewriteRules move engines and secrets to new paths during restore. All
+// rules match source paths:
+//
+//   - Mounts rename a mount and optionally move its secrets under a prefix
+//     of the new mount, e.g. ats-secret/foo => apps/ats/foo
+//   - Prefixes replace the leading part of secret paths
+//   - Regex rewrites secret paths with a regular expression
+//
+// Prefix and regex rules apply to every mount unless Mount is set; within
+// each list the first matching rule wins. Policy paths are rewritten the same
+// way so ACLs keep matching the moved secrets.
+//
+// A nil *RewriteRules leaves every path unchanged.
+type RewriteRules struct {
+	Mounts   []MountRename   `json:"mounts"`
+	Prefixes []PrefixRewrite `json:"prefixes"`
+	Regex    []RegexRewrite  `json:"regex"`
+}
+
+type MountRename struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+type PrefixRewrite struct {
+	Mount string `json:"mount,omitempty"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type RegexRewrite struct {
+	Mount   string `json:"mount,omitempty"`
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+
+	re *regexp.Regexp
+}
+
+// kvAPISegments follow the mount in KV v2 policy paths
+var kvAPISegments = []string{"data/", "metadata/", "delete/", "undelete/", "destroy/", "subkeys/"}
+
+func LoadRewriteRules(path string) (*RewriteRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rewrite rules: %w", err)
+	}
+
+	var rules RewriteRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse rewrite rules: %w", err)
+	}
+
+	for i := range rules.Mounts {
+		rename := &rules.Mounts[i]
+		if rename.From == "" || rename.To == "" {
+			return nil, fmt.Errorf("mount rename %d needs both from and to", i+1)
+		}
+		rename.From = normalizeMount(rename.From)
+		rename.To = normalizeMount(rename.To)
+		if rename.Prefix != "" {
+			rename.Prefix = strings.Trim(rename.Prefix, "/") + "/"
+		}
+	}
+	for i := range rules.Prefixes {
+		rules.Prefixes[i].Mount = normalizeMount(rules.Prefixes[i].Mount)
+	}
+	for i := range rules.Regex {
+		rule := &rules.Regex[i]
+		rule.Mount = normalizeMount(rule.Mount)
+		re, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", rule.Match, err)
+		}
+		rule.re = re
+	}
+
+	return &rules, nil
+}
+
+// Mount returns the target path of a source mount
+func (r *RewriteRules) Mount(mountPath string) string {
+	if rename := r.mountRename(mountPath); rename != nil {
+		return rename.To
+	}
+	return mountPath
+}
+
+// Secret returns the target path, relative to the target mount, of a secret
+// in a source mount
+func (r *RewriteRules) Secret(mountPath, secretPath string) string {
+	if r == nil {
+		return secretPath
+	}
+
+	for _, rule := range r.Prefixes {
+		if (rule.Mount == "" || rule.Mount == mountPath) && strings.HasPrefix(secretPath, rule.From) {
+			secretPath = rule.To + strings.TrimPrefix(secretPath, rule.From)
+			break
+		}
+	}
+
+	for _, rule := range r.Regex {
+		if (rule.Mount == "" || rule.Mount == mountPath) && rule.re.MatchString(secretPath) {
+			secretPath = rule.re.ReplaceAllString(secretPath, rule.Replace)
+			break
+		}
+	}
+
+	if rename := r.mountRename(mountPath); rename != nil {
+		secretPath = rename.Prefix + secretPath
+	}
+	return secretPath
+}
+
+// PolicyPath rewrites a single ACL path. mounts are the source mounts known
+// from the backup; a path outside all of them is left alone.
+func (r *RewriteRules) PolicyPath(path string, mounts []string) string {
+	if r == nil {
+		return path
+	}
+
+	mountPath := r.longestMount(path, mounts)
+	if mountPath == "" {
+		return path
+	}
+	rest := strings.TrimPrefix(path, mountPath)
+
+	// KV v2 paths carry an API segment between the mount and the secret. On
+	// its own, e.g. mount/metadata to list the root, it names the root of
+	// the moved secrets.
+	segment := ""
+	for _, s := range kvAPISegments {
+		if rest == strings.TrimSuffix(s, "/") {
+			root := strings.TrimSuffix(r.Secret(mountPath, ""), "/")
+			if root == "" {
+				return r.Mount(mountPath) + rest
+			}
+			return r.Mount(mountPath) + s + root
+		}
+		if strings.HasPrefix(rest, s) {
+			segment = s
+			rest = strings.TrimPrefix(rest, s)
+			break
+		}
+	}
+
+	return r.Mount(mountPath) + segment + r.Secret(mountPath, rest)
+}
+
+// ruleMounts lists every source mount named by a rule
+func (r *RewriteRules) ruleMounts() []string {
+	if r == nil {
+		return nil
+	}
+
+	var mounts []string
+	for _, rename := range r.Mounts {
+		mounts = append(mounts, rename.From)
+	}
+	for _, rule := range r.Prefixes {
+		if rule.Mount != "" {
+			mounts = append(mounts, rule.Mount)
+		}
+	}
+	for _, rule := range r.Regex {
+		if rule.Mount != "" {
+			mounts = append(mounts, rule.Mount)
+		}
+	}
+	return mounts
+}
+
+func (r *RewriteRules) mountRename(mountPath string) *MountRename {
+	if r == nil {
+		return nil
+	}
+	for i := range r.Mounts {
+		if r.Mounts[i].From == mountPath {
+			return &r.Mounts[i]
+		}
+	}
+	return nil
+}
+
+func (r *RewriteRules) longestMount(path string, mounts []string) string {
+	best := ""
+	candidates := append(append([]string{}, mounts...), r.ruleMounts()...)
+	for _, mount := range candidates {
+		if strings.HasPrefix(path, mount) && len(mount) > len(best) {
+			best = mount
+		}
+	}
+	return best
+}
+
+// normalizeMount gives a mount path the trailing slash Vault uses
+func normalizeMount(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return path + "/"
+}
@@ -0,0 +1,135 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testRewriteRules = `{
+  "mounts": [
+    {"from": "ats-secret", "to": "apps", "prefix": "ats"},
+    {"from": "secret/", "to": "kv"}
+  ],
+  "prefixes": [
+    {"mount": "kv1", "from": "old/", "to": "new/"},
+    {"from": "legacy/", "to": "current/"}
+  ],
+  "regex": [
+    {"mount": "kv1", "match": "^team-(\\w+)/", "replace": "teams/$1/"}
+  ]
+}`
+
+func loadTestRewriteRules(t *testing.T, rules string) (*RewriteRules, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rewrite.json")
+	if err := os.WriteFile(path, []byte(rules), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadRewriteRules(path)
+}
+
+func TestLoadRewriteRulesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+	}{
+		{"invalid json", `{"mounts": [`},
+		{"rename without to", `{"mounts": [{"from": "secret"}]}`},
+		{"invalid regex", `{"regex": [{"match": "(", "replace": ""}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadTestRewriteRules(t, tt.rules); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestRewriteMount(t *testing.T) {
+	rules, err := loadTestRewriteRules(t, testRewriteRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rules *RewriteRules
+		mount string
+		want  string
+	}{
+		{rules, "ats-secret/", "apps/"},
+		{rules, "secret/", "kv/"},
+		{rules, "kv1/", "kv1/"},
+		{nil, "secret/", "secret/"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rules.Mount(tt.mount); got != tt.want {
+			t.Errorf("Mount(%q) = %q, want %q", tt.mount, got, tt.want)
+		}
+	}
+}
+
+func TestRewriteSecret(t *testing.T) {
+	rules, err := loadTestRewriteRules(t, testRewriteRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rules  *RewriteRules
+		mount  string
+		secret string
+		want   string
+	}{
+		{rules, "kv1/", "old/a", "new/a"},
+		{rules, "kv1/", "team-web/a", "teams/web/a"},
+		{rules, "kv1/", "legacy/a", "current/a"},
+		{rules, "secret/", "old/a", "old/a"},
+		{rules, "secret/", "team-web/a", "team-web/a"},
+		{rules, "secret/", "legacy/a", "current/a"},
+		{rules, "ats-secret/", "db/password", "ats/db/password"},
+		{rules, "ats-secret/", "legacy/a", "ats/current/a"},
+		{nil, "kv1/", "old/a", "old/a"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rules.Secret(tt.mount, tt.secret); got != tt.want {
+			t.Errorf("Secret(%q, %q) = %q, want %q", tt.mount, tt.secret, got, tt.want)
+		}
+	}
+}
+
+func TestRewritePolicyPath(t *testing.T) {
+	rules, err := loadTestRewriteRules(t, testRewriteRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mounts := []string{"ats-secret/", "secret/", "kv1/"}
+
+	tests := []struct {
+		rules *RewriteRules
+		path  string
+		want  string
+	}{
+		{rules, "ats-secret/data/db/*", "apps/data/ats/db/*"},
+		{rules, "ats-secret/metadata/", "apps/metadata/ats/"},
+		{rules, "ats-secret/metadata", "apps/metadata/ats"},
+		{rules, "ats-secret/data", "apps/data/ats"},
+		{rules, "ats-secret/*", "apps/ats/*"},
+		{rules, "secret/data/legacy/*", "kv/data/current/*"},
+		{rules, "secret/metadata", "kv/metadata"},
+		{rules, "secret/metadata/*", "kv/metadata/*"},
+		{rules, "kv1/team-web/a", "kv1/teams/web/a"},
+		{rules, "sys/policies/acl/*", "sys/policies/acl/*"},
+		{nil, "secret/data/legacy/*", "secret/data/legacy/*"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rules.PolicyPath(tt.path, mounts); got != tt.want {
+			t.Errorf("PolicyPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
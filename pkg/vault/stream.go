@@ -0,0 +1,400 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// StreamFormat identifies the newline-delimited backup format. Each line is a
+// single Record, so neither backup nor restore has to hold the whole Vault in
+// memory.
+const StreamFormat = "vault-migrator/stream/v1"
+
+const (
+	recordHeader       = "header"
+	recordEngine       = "engine"
+	recordSecret       = "secret"
+	recordEngineEnd    = "engine_end"
+	recordPolicy       = "policy"
+	recordAuthMethod   = "auth_method"
+	recordEntity       = "entity"
+	recordGroup        = "group"
+	recordNamespace    = "namespace"
+	recordNamespaceEnd = "namespace_end"
+)
+
+type BackupHeader struct {
+	Format       string    `json:"format"`
+	Timestamp    time.Time `json:"timestamp"`
+	VaultVersion string    `json:"vault_version"`
+
+	// Engines are the mounts the backup was limited to, empty for all
+	Engines []string `json:"engines,omitempty"`
+}
+
+type Record struct {
+	Kind          string              `json:"kind"`
+	Header        *BackupHeader       `json:"header,omitempty"`
+	Engine        *SecretEngineBackup `json:"engine,omitempty"`
+	EnginePath    string              `json:"engine_path,omitempty"`
+	Secret        *SecretBackup       `json:"secret,omitempty"`
+	Policy        *PolicyBackup       `json:"policy,omitempty"`
+	AuthMethod    *AuthMethodBackup   `json:"auth_method,omitempty"`
+	Entity        *EntityBackup       `json:"entity,omitempty"`
+	Group         *GroupBackup        `json:"group,omitempty"`
+	Namespace     *NamespaceBackup    `json:"namespace,omitempty"`
+	NamespacePath string              `json:"namespace_path,omitempty"`
+}
+
+type BackupStats struct {
+	SecretEngines int
+	Secrets       int
+	Policies      int
+	AuthMethods   int
+	Entities      int
+	Groups        int
+	Namespaces    int
+}
+
+// BackupSink receives backup data one record at a time. An engine is announced
+// with WriteEngine (without its secrets), followed by its secrets and EndEngine.
+// Records between BeginNamespace and EndNamespace belong to that child
+// namespace; namespaces nest.
+type BackupSink interface {
+	WriteHeader(header BackupHeader) error
+	WriteEngine(engine SecretEngineBackup) error
+	WriteSecret(enginePath string, secret SecretBackup) error
+	EndEngine(enginePath string) error
+	WritePolicy(policy PolicyBackup) error
+	WriteAuthMethod(auth AuthMethodBackup) error
+	WriteEntity(entity EntityBackup) error
+	WriteGroup(group GroupBackup) error
+	BeginNamespace(namespace NamespaceBackup) error
+	EndNamespace(path string) error
+}
+
+// BackupSource replays backup data into a sink.
+type BackupSource interface {
+	Replay(sink BackupSink) error
+}
+
+// StreamWriter writes records as newline-delimited JSON.
+type StreamWriter struct {
+	encoder *json.Encoder
+	stats   BackupStats
+}
+
+func NewStreamWriter(w io.Writer) *StreamWriter {
+	return &StreamWriter{encoder: json.NewEncoder(w)}
+}
+
+func (s *StreamWriter) Stats() BackupStats {
+	return s.stats
+}
+
+func (s *StreamWriter) WriteHeader(header BackupHeader) error {
+	header.Format = StreamFormat
+	return s.encoder.Encode(Record{Kind: recordHeader, Header: &header})
+}
+
+func (s *StreamWriter) WriteEngine(engine SecretEngineBackup) error {
+	engine.Secrets = nil
+	s.stats.SecretEngines++
+	return s.encoder.Encode(Record{Kind: recordEngine, Engine: &engine})
+}
+
+func (s *StreamWriter) WriteSecret(enginePath string, secret SecretBackup) error {
+	s.stats.Secrets++
+	return s.encoder.Encode(Record{Kind: recordSecret, EnginePath: enginePath, Secret: &secret})
+}
+
+func (s *StreamWriter) EndEngine(enginePath string) error {
+	return s.encoder.Encode(Record{Kind: recordEngineEnd, EnginePath: enginePath})
+}
+
+func (s *StreamWriter) WritePolicy(policy PolicyBackup) error {
+	s.stats.Policies++
+	return s.encoder.Encode(Record{Kind: recordPolicy, Policy: &policy})
+}
+
+func (s *StreamWriter) WriteAuthMethod(auth AuthMethodBackup) error {
+	s.stats.AuthMethods++
+	return s.encoder.Encode(Record{Kind: recordAuthMethod, AuthMethod: &auth})
+}
+
+func (s *StreamWriter) WriteEntity(entity EntityBackup) error {
+	s.stats.Entities++
+	return s.encoder.Encode(Record{Kind: recordEntity, Entity: &entity})
+}
+
+func (s *StreamWriter) WriteGroup(group GroupBackup) error {
+	s.stats.Groups++
+	return s.encoder.Encode(Record{Kind: recordGroup, Group: &group})
+}
+
+func (s *StreamWriter) BeginNamespace(namespace NamespaceBackup) error {
+	s.stats.Namespaces++
+	return s.encoder.Encode(Record{Kind: recordNamespace, Namespace: &namespace})
+}
+
+func (s *StreamWriter) EndNamespace(path string) error {
+	return s.encoder.Encode(Record{Kind: recordNamespaceEnd, NamespacePath: path})
+}
+
+// StreamReader replays a newline-delimited backup one record at a time.
+type StreamReader struct {
+	decoder *json.Decoder
+	header  BackupHeader
+}
+
+// OpenBackupSource detects the backup format. Stream files are read lazily;
+// legacy single-document backups are decoded into a BackupData.
+func OpenBackupSource(r io.Reader) (BackupSource, error) {
+	decoder := json.NewDecoder(r)
+
+	var first json.RawMessage
+	if err := decoder.Decode(&first); err != nil {
+		return nil, err
+	}
+
+	var record Record
+	if err := json.Unmarshal(first, &record); err == nil && record.Kind == recordHeader && record.Header != nil {
+		return &StreamReader{decoder: decoder, header: *record.Header}, nil
+	}
+
+	var backup BackupData
+	if err := json.Unmarshal(first, &backup); err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
+
+func (s *StreamReader) Replay(sink BackupSink) error {
+	if err := sink.WriteHeader(s.header); err != nil {
+		return err
+	}
+
+	for {
+		var record Record
+		if err := s.decoder.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read backup record: %w", err)
+		}
+
+		if err := replayRecord(sink, record); err != nil {
+			return err
+		}
+	}
+}
+
+func replayRecord(sink BackupSink, record Record) error {
+	switch record.Kind {
+	case recordEngine:
+		if record.Engine != nil {
+			return sink.WriteEngine(*record.Engine)
+		}
+	case recordSecret:
+		if record.Secret != nil {
+			return sink.WriteSecret(record.EnginePath, *record.Secret)
+		}
+	case recordEngineEnd:
+		return sink.EndEngine(record.EnginePath)
+	case recordPolicy:
+		if record.Policy != nil {
+			return sink.WritePolicy(*record.Policy)
+		}
+	case recordAuthMethod:
+		if record.AuthMethod != nil {
+			return sink.WriteAuthMethod(*record.AuthMethod)
+		}
+	case recordEntity:
+		if record.Entity != nil {
+			return sink.WriteEntity(*record.Entity)
+		}
+	case recordGroup:
+		if record.Group != nil {
+			return sink.WriteGroup(*record.Group)
+		}
+	case recordNamespace:
+		if record.Namespace != nil {
+			return sink.BeginNamespace(*record.Namespace)
+		}
+	case recordNamespaceEnd:
+		return sink.EndNamespace(record.NamespacePath)
+	default:
+		return fmt.Errorf("unknown backup record kind %q", record.Kind)
+	}
+	return nil
+}
+
+func (b *BackupData) Replay(sink BackupSink) error {
+	header := BackupHeader{
+		Timestamp:    b.Timestamp,
+		VaultVersion: b.VaultVersion,
+	}
+	if err := sink.WriteHeader(header); err != nil {
+		return err
+	}
+	return b.replayNamespace(sink)
+}
+
+// replayNamespace replays the contents of one namespace, then its children
+func (b *BackupData) replayNamespace(sink BackupSink) error {
+	for _, engine := range b.SecretEngines {
+		if err := sink.WriteEngine(engine); err != nil {
+			return err
+		}
+		for _, secret := range engine.Secrets {
+			if err := sink.WriteSecret(engine.Path, secret); err != nil {
+				return err
+			}
+		}
+		if err := sink.EndEngine(engine.Path); err != nil {
+			return err
+		}
+	}
+
+	for _, policy := range b.Policies {
+		if err := sink.WritePolicy(policy); err != nil {
+			return err
+		}
+	}
+
+	for _, auth := range b.AuthMethods {
+		if err := sink.WriteAuthMethod(auth); err != nil {
+			return err
+		}
+	}
+
+	for _, entity := range b.Entities {
+		if err := sink.WriteEntity(entity); err != nil {
+			return err
+		}
+	}
+
+	for _, group := range b.Groups {
+		if err := sink.WriteGroup(group); err != nil {
+			return err
+		}
+	}
+
+	for _, child := range b.Namespaces {
+		var namespace NamespaceBackup
+		if child.Namespace != nil {
+			namespace = *child.Namespace
+		}
+		if err := sink.BeginNamespace(namespace); err != nil {
+			return err
+		}
+		if err := child.replayNamespace(sink); err != nil {
+			return err
+		}
+		if err := sink.EndNamespace(namespace.Path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BackupCollector is a sink that assembles a complete BackupData in memory.
+type BackupCollector struct {
+	Data *BackupData
+
+	// stack holds the namespaces being collected, innermost last
+	stack []*collectorFrame
+}
+
+type collectorFrame struct {
+	data    *BackupData
+	engines map[string]int
+}
+
+func NewBackupCollector() *BackupCollector {
+	data := &BackupData{}
+	return &BackupCollector{
+		Data:  data,
+		stack: []*collectorFrame{{data: data, engines: make(map[string]int)}},
+	}
+}
+
+func (b *BackupCollector) current() *collectorFrame {
+	return b.stack[len(b.stack)-1]
+}
+
+func (b *BackupCollector) WriteHeader(header BackupHeader) error {
+	b.Data.Timestamp = header.Timestamp
+	b.Data.VaultVersion = header.VaultVersion
+	return nil
+}
+
+func (b *BackupCollector) WriteEngine(engine SecretEngineBackup) error {
+	frame := b.current()
+	engine.Secrets = nil
+	frame.engines[engine.Path] = len(frame.data.SecretEngines)
+	frame.data.SecretEngines = append(frame.data.SecretEngines, engine)
+	return nil
+}
+
+func (b *BackupCollector) WriteSecret(enginePath string, secret SecretBackup) error {
+	frame := b.current()
+	i, ok := frame.engines[enginePath]
+	if !ok {
+		return fmt.Errorf("secret %s references unknown engine %s", secret.Path, enginePath)
+	}
+	frame.data.SecretEngines[i].Secrets = append(frame.data.SecretEngines[i].Secrets, secret)
+	return nil
+}
+
+func (b *BackupCollector) EndEngine(enginePath string) error {
+	return nil
+}
+
+func (b *BackupCollector) WritePolicy(policy PolicyBackup) error {
+	data := b.current().data
+	data.Policies = append(data.Policies, policy)
+	return nil
+}
+
+func (b *BackupCollector) WriteAuthMethod(auth AuthMethodBackup) error {
+	data := b.current().data
+	data.AuthMethods = append(data.AuthMethods, auth)
+	return nil
+}
+
+func (b *BackupCollector) WriteEntity(entity EntityBackup) error {
+	data := b.current().data
+	data.Entities = append(data.Entities, entity)
+	return nil
+}
+
+func (b *BackupCollector) WriteGroup(group GroupBackup) error {
+	data := b.current().data
+	data.Groups = append(data.Groups, group)
+	return nil
+}
+
+func (b *BackupCollector) BeginNamespace(namespace NamespaceBackup) error {
+	b.stack = append(b.stack, &collectorFrame{
+		data:    &BackupData{Namespace: &namespace},
+		engines: make(map[string]int),
+	})
+	return nil
+}
+
+// EndNamespace attaches the finished namespace to its parent. Children are
+// only appended once complete, so the parent's slice never moves under them.
+func (b *BackupCollector) EndNamespace(path string) error {
+	if len(b.stack) < 2 {
+		return fmt.Errorf("unexpected end of namespace %s", path)
+	}
+	child := b.current().data
+	b.stack = b.stack[:len(b.stack)-1]
+
+	parent := b.current().data
+	parent.Namespaces = append(parent.Namespaces, *child)
+	return nil
+}
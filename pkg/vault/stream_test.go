@@ -0,0 +1,147 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testBackup() *BackupData {
+	return &BackupData{
+		Timestamp:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		VaultVersion: "1.15.0",
+		SecretEngines: []SecretEngineBackup{
+			{
+				Path: "secret/",
+				Type: "kv",
+				Secrets: []SecretBackup{
+					{Path: "app/db", Versions: []SecretVersion{{Version: 1, Data: map[string]interface{}{"password": "one"}}}},
+					{Path: "app/api", Versions: []SecretVersion{{Version: 1, Data: map[string]interface{}{"key": "two"}}}},
+				},
+			},
+			{Path: "transit/", Type: "transit"},
+		},
+		Policies:    []PolicyBackup{{Name: "app", Policy: `path "secret/*" { capabilities = ["read"] }`}},
+		AuthMethods: []AuthMethodBackup{{Path: "userpass/", Type: "userpass", Users: []UserBackup{{Name: "alice", Data: map[string]interface{}{"policies": "app"}}}}},
+	}
+}
+
+// writeTestStream replays data into a StreamWriter
+func writeTestStream(t *testing.T, data *BackupData) (*bytes.Buffer, BackupStats) {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := NewStreamWriter(&buf)
+	if err := data.Replay(writer); err != nil {
+		t.Fatal(err)
+	}
+	return &buf, writer.Stats()
+}
+
+func collectTestStream(data []byte) (*BackupData, error) {
+	source, err := OpenBackupSource(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	collector := NewBackupCollector()
+	if err := source.Replay(collector); err != nil {
+		return nil, err
+	}
+	return collector.Data, nil
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	want := testBackup()
+	buf, stats := writeTestStream(t, want)
+
+	if stats != (BackupStats{SecretEngines: 2, Secrets: 2, Policies: 1, AuthMethods: 1}) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 9 {
+		t.Errorf("stream has %d records, want 9", lines)
+	}
+
+	got, err := collectTestStream(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the backup:\ngot  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestLegacyBackupSource(t *testing.T) {
+	want := testBackup()
+	data, err := json.MarshalIndent(want, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := collectTestStream(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("legacy backup changed:\ngot  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestStreamDamagedRecord(t *testing.T) {
+	buf, _ := writeTestStream(t, testBackup())
+	stream := strings.TrimSuffix(buf.String(), "\n")
+	last := strings.LastIndex(stream, "\n") + 1
+
+	tests := []struct {
+		name    string
+		stream  string
+		wantErr string
+	}{
+		{"truncated", stream[:last+len(stream[last:])/2], "failed to read backup record"},
+		{"corrupt", stream[:last] + "not json\n", "failed to read backup record"},
+		{"unknown kind", stream[:last] + `{"kind":"bogus"}` + "\n", `unknown backup record kind "bogus"`},
+		{"secret without engine", stream[:last] + `{"kind":"secret","engine_path":"gone/","secret":{"path":"a"}}` + "\n", "unknown engine gone/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := collectTestStream([]byte(tt.stream))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestStreamNestedNamespaces(t *testing.T) {
+	dev := testBackup()
+	dev.Timestamp, dev.VaultVersion = time.Time{}, ""
+	dev.Namespace = &NamespaceBackup{Path: "team-a/dev/"}
+
+	teamA := &BackupData{
+		Namespace:   &NamespaceBackup{Path: "team-a/", CustomMetadata: map[string]string{"owner": "a"}},
+		Policies:    []PolicyBackup{{Name: "team-a", Policy: `path "kv/*" { capabilities = ["read"] }`}},
+		Namespaces:  []BackupData{*dev},
+		AuthMethods: []AuthMethodBackup{{Path: "approle/", Type: "approle"}},
+	}
+	teamB := &BackupData{
+		Namespace:     &NamespaceBackup{Path: "team-b/"},
+		SecretEngines: []SecretEngineBackup{{Path: "kv/", Type: "kv", Secrets: []SecretBackup{{Path: "b"}}}},
+	}
+	want := testBackup()
+	want.Namespaces = []BackupData{*teamA, *teamB}
+
+	buf, stats := writeTestStream(t, want)
+	if stats.Namespaces != 3 || stats.SecretEngines != 5 || stats.Secrets != 5 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	got, err := collectTestStream(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip changed the backup:\ngot  %#v\nwant %#v", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+package vault
+
+import (
+	"fmt"
+)
+
+func init() {
+	RegisterEngineHandler("transit", transitHandler{})
+}
+
+// transitHandler carries transit keys, including their key material when
+// the key allows plaintext backup
+type transitHandler struct{}
+
+func (transitHandler) Backup(c *Client, engine *SecretEngineBackup) error {
+	keys, err := c.backupTransitKeys(engine.Path)
+	if err != nil {
+		return fmt.Errorf("failed to backup transit keys: %w", err)
+	}
+	engine.TransitKeys = keys
+	return nil
+}
+
+func (transitHandler) Restore(c *Client, mountPath string, engine SecretEngineBackup, opts RestoreOptions) error {
+	failed, err := c.restoreTransitKeys(mountPath, engine.TransitKeys, opts.Journal)
+	if err != nil {
+		return err
+	}
+	return failedEntries(failed)
+}
+
+func (transitHandler) Diff(report *DiffReport, mountPath string, left, right SecretEngineBackup, opts DiffOptions) {
+	diffTransitKeys(report, mountPath, left.TransitKeys, right.TransitKeys)
+}
+
+func (transitHandler) Plan(p *PlanContext, mountPath string, engine SecretEngineBackup) {
+	for _, key := range engine.TransitKeys {
+		path := mountPath + "keys/" + key.Name
+		resp, err := p.Read(path)
+		switch {
+		case err != nil:
+			p.Add("transit key", path, PlanConflict, err.Error())
+		case resp != nil && resp.Data != nil:
+			p.Add("transit key", path, PlanConflict, "key already exists in the target")
+		case key.Backup == "":
+			p.Add("transit key", path, PlanConflict, "key material was not exportable")
+		default:
+			p.Add("transit key", path, PlanCreate, "type "+key.Type)
+		}
+	}
+}
+
+// transitConfigFields are the key settings carried over on restore
+var transitConfigFields = []string{
+	"min_decryption_version",
+	"min_encryption_version",
+	"deletion_allowed",
+	"exportable",
+	"allow_plaintext_backup",
+	"auto_rotate_period",
+}
+
+func (c *Client) backupTransitKeys(mountPath string) ([]TransitKeyBackup, error) {
+	var keys []TransitKeyBackup
+
+	names, err := c.listKeys(mountPath + "keys")
+	if err != nil {
+		return nil, err
+	}
+
+	var skipped []string
+	for _, name := range names {
+		resp, err := c.client.Logical().Read(mountPath + "keys/" + name)
+		if err != nil || resp == nil || resp.Data == nil {
+			fmt.Printf("    Warning: failed to read transit key %s: %v\n", name, err)
+			continue
+		}
+
+		key := TransitKeyBackup{
+			Name:   name,
+			Type:   stringValue(resp.Data["type"]),
+			Config: make(map[string]interface{}),
+		}
+		for _, field := range transitConfigFields {
+			if v, ok := resp.Data[field]; ok {
+				key.Config[field] = v
+			}
+		}
+
+		// Vault only hands out key material for keys that allow it
+		exportable, _ := resp.Data["exportable"].(bool)
+		plaintextBackup, _ := resp.Data["allow_plaintext_backup"].(bool)
+		if exportable && plaintextBackup {
+			backupResp, err := c.client.Logical().Read(mountPath + "backup/" + name)
+			if err != nil || backupResp == nil || backupResp.Data == nil {
+				fmt.Printf("    Warning: failed to export transit key %s: %v\n", name, err)
+			} else {
+				key.Backup = stringValue(backupResp.Data["backup"])
+			}
+		}
+
+		if key.Backup == "" {
+			skipped = append(skipped, name)
+		}
+		keys = append(keys, key)
+	}
+
+	for _, name := range skipped {
+		fmt.Printf("    Warning: transit key %s is not exportable with allow_plaintext_backup; its key material is NOT in the backup\n", name)
+	}
+	fmt.Printf("    Backed up %d transit keys (%d without key material)\n", len(keys), len(skipped))
+
+	return keys, nil
+}
+
+// restoreTransitKeys returns how many keys failed. A key that exists cannot
+// be restored again, so restored keys are journaled and a resumed run only
+// reapplies their config.
+func (c *Client) restoreTransitKeys(mountPath string, keys []TransitKeyBackup, journal *Journal) (int, error) {
+	restored, failed := 0, 0
+	for _, key := range keys {
+		journaled := journalKey("transit key", mountPath+key.Name)
+		if !journal.Done(journaled) {
+			if key.Backup == "" {
+				fmt.Printf("    Warning: transit key %s has no exported key material and cannot be restored; data encrypted with it will not decrypt\n", key.Name)
+				continue
+			}
+
+			_, err := c.client.Logical().Write(mountPath+"restore/"+key.Name, map[string]interface{}{
+				"backup": key.Backup,
+			})
+			if err != nil {
+				c.reportFailure("    Warning: failed to restore transit key %s: %v\n", key.Name, err)
+				failed++
+				continue
+			}
+			if err := journal.Record(journaled); err != nil {
+				return failed, err
+			}
+		}
+
+		if len(key.Config) > 0 {
+			if _, err := c.client.Logical().Write(mountPath+"keys/"+key.Name+"/config", key.Config); err != nil {
+				c.reportFailure("    Warning: failed to configure transit key %s: %v\n", key.Name, err)
+				failed++
+			}
+		}
+		restored++
+	}
+
+	fmt.Printf("    Restored %d of %d transit keys\n", restored, len(keys))
+	return failed, nil
+}
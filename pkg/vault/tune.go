@@ -0,0 +1,159 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// tuneFields are the mount settings restored from a backed-up config, keyed
+// by their name in sys/mounts/<path>/tune
+var tuneFields = []string{
+	"default_lease_ttl",
+	"max_lease_ttl",
+	"force_no_cache",
+	"audit_non_hmac_request_keys",
+	"audit_non_hmac_response_keys",
+	"listing_visibility",
+	"passthrough_request_headers",
+	"allowed_response_headers",
+	"token_type",
+	"allowed_managed_keys",
+}
+
+type TuneChange struct {
+	Field string
+	From  interface{}
+	To    interface{}
+}
+
+func (t TuneChange) String() string {
+	return fmt.Sprintf("%s: %v → %v", t.Field, t.From, t.To)
+}
+
+// mountConfigInput builds the config for a new mount from a backed-up config
+func mountConfigInput(config map[string]interface{}) api.MountConfigInput {
+	input := api.MountConfigInput{}
+	desired := decodeMountConfig(config)
+	for _, field := range tuneFields {
+		if _, ok := config[field]; ok {
+			setTuneField(&input, field, desired, false)
+		}
+	}
+	return input
+}
+
+// tuneChanges compares the current config of a mount with a backed-up one.
+// Only fields present in the backup are considered.
+func tuneChanges(current api.MountConfigOutput, config map[string]interface{}) []TuneChange {
+	var changes []TuneChange
+	currentMap := convertToMap(current)
+	for _, field := range tuneFields {
+		desired, ok := config[field]
+		if !ok || equalData(currentMap[field], desired) {
+			continue
+		}
+		// Empty lists and strings are omitted by Vault and cannot be tuned
+		// back to empty through the API
+		if isEmptyValue(desired) {
+			continue
+		}
+		changes = append(changes, TuneChange{Field: field, From: currentMap[field], To: desired})
+	}
+	return changes
+}
+
+// tuneMount applies changed settings to an existing mount. path is the sys
+// mount path, e.g. "secret" or "auth/userpass".
+func (c *Client) tuneMount(path string, current api.MountConfigOutput, config map[string]interface{}) error {
+	changes := tuneChanges(current, config)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// force_no_cache is always sent, so keep the current value unless it changes
+	input := api.MountConfigInput{ForceNoCache: current.ForceNoCache}
+	desired := decodeMountConfig(config)
+	for _, change := range changes {
+		setTuneField(&input, change.Field, desired, true)
+	}
+
+	if err := c.client.Sys().TuneMount(path, input); err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		fmt.Printf("    Tuned %s\n", change)
+	}
+	return nil
+}
+
+func decodeMountConfig(config map[string]interface{}) api.MountConfigOutput {
+	var output api.MountConfigOutput
+	data, err := json.Marshal(config)
+	if err == nil {
+		json.Unmarshal(data, &output)
+	}
+	return output
+}
+
+// setTuneField copies one field into input. A zero TTL means "system
+// default", which has to be spelled out when tuning an existing mount.
+func setTuneField(input *api.MountConfigInput, field string, desired api.MountConfigOutput, tuning bool) {
+	ttl := func(seconds int) string {
+		switch {
+		case seconds > 0:
+			return fmt.Sprintf("%ds", seconds)
+		case tuning:
+			return "system"
+		}
+		return ""
+	}
+
+	switch field {
+	case "default_lease_ttl":
+		input.DefaultLeaseTTL = ttl(desired.DefaultLeaseTTL)
+	case "max_lease_ttl":
+		input.MaxLeaseTTL = ttl(desired.MaxLeaseTTL)
+	case "force_no_cache":
+		input.ForceNoCache = desired.ForceNoCache
+	case "audit_non_hmac_request_keys":
+		input.AuditNonHMACRequestKeys = desired.AuditNonHMACRequestKeys
+	case "audit_non_hmac_response_keys":
+		input.AuditNonHMACResponseKeys = desired.AuditNonHMACResponseKeys
+	case "listing_visibility":
+		input.ListingVisibility = desired.ListingVisibility
+	case "passthrough_request_headers":
+		input.PassthroughRequestHeaders = desired.PassthroughRequestHeaders
+	case "allowed_response_headers":
+		input.AllowedResponseHeaders = desired.AllowedResponseHeaders
+	case "token_type":
+		input.TokenType = desired.TokenType
+	case "allowed_managed_keys":
+		input.AllowedManagedKeys = desired.AllowedManagedKeys
+	}
+}
+
+func isEmptyValue(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case []interface{}:
+		return len(val) == 0
+	case []string:
+		return len(val) == 0
+	}
+	return false
+}
+
+func tuneChangeFields(changes []TuneChange) string {
+	var fields []string
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	return strings.Join(fields, ", ")
+}
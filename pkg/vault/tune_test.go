@@ -0,0 +1,76 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestTuneChanges(t *testing.T) {
+	current := api.MountConfigOutput{
+		DefaultLeaseTTL:   3600,
+		MaxLeaseTTL:       86400,
+		ListingVisibility: "unauth",
+		TokenType:         "default-service",
+	}
+
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+		want   []TuneChange
+	}{
+		{
+			name:   "unchanged",
+			config: map[string]interface{}{"default_lease_ttl": float64(3600), "max_lease_ttl": float64(86400), "listing_visibility": "unauth"},
+		},
+		{
+			name:   "changed ttl",
+			config: map[string]interface{}{"default_lease_ttl": float64(7200), "max_lease_ttl": float64(86400)},
+			want:   []TuneChange{{Field: "default_lease_ttl", From: float64(3600), To: float64(7200)}},
+		},
+		{
+			name:   "changed strings and lists",
+			config: map[string]interface{}{"token_type": "batch", "audit_non_hmac_request_keys": []interface{}{"user"}},
+			want: []TuneChange{
+				{Field: "audit_non_hmac_request_keys", From: nil, To: []interface{}{"user"}},
+				{Field: "token_type", From: "default-service", To: "batch"},
+			},
+		},
+		{
+			name:   "empty values cannot be tuned back",
+			config: map[string]interface{}{"listing_visibility": "", "passthrough_request_headers": []interface{}{}},
+		},
+		{
+			name:   "fields that are not tuned",
+			config: map[string]interface{}{"plugin_version": "v1.2.3", "options": map[string]interface{}{"version": "2"}},
+		},
+		{
+			name: "missing config",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tuneChanges(current, tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tuneChanges = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetTuneFieldTTL(t *testing.T) {
+	desired := decodeMountConfig(map[string]interface{}{"default_lease_ttl": float64(0), "max_lease_ttl": float64(600)})
+
+	var input api.MountConfigInput
+	setTuneField(&input, "default_lease_ttl", desired, true)
+	setTuneField(&input, "max_lease_ttl", desired, true)
+	if input.DefaultLeaseTTL != "system" || input.MaxLeaseTTL != "600s" {
+		t.Errorf("tuning TTLs = %q, %q, want \"system\", \"600s\"", input.DefaultLeaseTTL, input.MaxLeaseTTL)
+	}
+
+	input = mountConfigInput(map[string]interface{}{"default_lease_ttl": float64(0), "max_lease_ttl": float64(600)})
+	if input.DefaultLeaseTTL != "" || input.MaxLeaseTTL != "600s" {
+		t.Errorf("new mount TTLs = %q, %q, want \"\", \"600s\"", input.DefaultLeaseTTL, input.MaxLeaseTTL)
+	}
+}
@@ -8,6 +8,19 @@ type BackupData struct {
 	SecretEngines []SecretEngineBackup  `json:"secret_engines"`
 	Policies      []PolicyBackup        `json:"policies"`
 	AuthMethods   []AuthMethodBackup    `json:"auth_methods"`
+	Entities      []EntityBackup        `json:"entities,omitempty"`
+	Groups        []GroupBackup         `json:"groups,omitempty"`
+
+	// Namespace is set on child namespaces; Namespaces holds the children
+	Namespace  *NamespaceBackup `json:"namespace,omitempty"`
+	Namespaces []BackupData     `json:"namespaces,omitempty"`
+}
+
+// NamespaceBackup identifies a namespace by its path relative to the
+// namespace the backup was taken from, e.g. "team-a/" or "team-a/dev/".
+type NamespaceBackup struct {
+	Path           string            `json:"path"`
+	CustomMetadata map[string]string `json:"custom_metadata,omitempty"`
 }
 
 type SecretEngineBackup struct {
@@ -16,7 +29,19 @@ type SecretEngineBackup struct {
 	Description string                 `json:"description"`
 	Config      map[string]interface{} `json:"config"`
 	Options     map[string]interface{} `json:"options"`
-	Secrets     []SecretBackup         `json:"secrets"`
+	Secrets     []SecretBackup         `json:"secrets,omitempty"`
+	TransitKeys []TransitKeyBackup     `json:"transit_keys,omitempty"`
+	Database    *DatabaseBackup        `json:"database,omitempty"`
+	PKI         *PKIBackup             `json:"pki,omitempty"`
+}
+
+// TransitKeyBackup holds a transit key's settings and, when the key is
+// exportable with allow_plaintext_backup, the output of transit/backup.
+type TransitKeyBackup struct {
+	Name   string                 `json:"name"`
+	Type   string                 `json:"type"`
+	Config map[string]interface{} `json:"config"`
+	Backup string                 `json:"backup,omitempty"`
 }
 
 type SecretBackup struct {
@@ -57,6 +82,9 @@ type AuthMethodBackup struct {
 	Options     map[string]interface{} `json:"options"`
 	Roles       []RoleBackup           `json:"roles,omitempty"`
 	Users       []UserBackup           `json:"users,omitempty"`
+	Groups      []RoleBackup           `json:"groups,omitempty"`
+	AppRoles    []AppRoleBackup        `json:"approles,omitempty"`
+	Configs     []AuthConfigBackup     `json:"configs,omitempty"`
 }
 
 type RoleBackup struct {
@@ -67,11 +95,97 @@ type RoleBackup struct {
 type UserBackup struct {
 	Name string                 `json:"name"`
 	Data map[string]interface{} `json:"data"`
+
+	// PasswordHash is the base64 bcrypt hash of a userpass user, only
+	// present when backed up with UserpassHashes
+	PasswordHash string `json:"password_hash,omitempty"`
+}
+
+type EntityBackup struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Policies []string          `json:"policies,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Disabled bool              `json:"disabled"`
+	Aliases  []AliasBackup     `json:"aliases,omitempty"`
+}
+
+type GroupBackup struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Type            string            `json:"type"`
+	Policies        []string          `json:"policies,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	MemberEntityIDs []string          `json:"member_entity_ids,omitempty"`
+	MemberGroupIDs  []string          `json:"member_group_ids,omitempty"`
+	Alias           *AliasBackup      `json:"alias,omitempty"`
+}
+
+// AliasBackup is an entity or group alias. MountAccessor is only valid in the
+// source cluster; restore resolves MountPath to the target accessor.
+type AliasBackup struct {
+	Name           string            `json:"name"`
+	MountAccessor  string            `json:"mount_accessor"`
+	MountPath      string            `json:"mount_path"`
+	MountType      string            `json:"mount_type"`
+	CustomMetadata map[string]string `json:"custom_metadata,omitempty"`
+}
+
+type BackupOptions struct {
+	Engines      []string
+	SkipPolicies bool
+	SkipAuth     bool
+	SkipIdentity bool
+
+	// Recursive also backs up every child namespace
+	Recursive bool
+
+	// Concurrency is the number of secrets listed and read in parallel
+	Concurrency int
+
+	// UserpassHashes reads the password hashes of userpass users from
+	// sys/raw, which needs sudo on it in the root namespace
+	UserpassHashes bool
 }
 
 type RestoreOptions struct {
 	Engines         []string
 	SkipPolicies    bool
 	SkipAuth        bool
+	SkipIdentity    bool
 	DefaultPassword string
+
+	// PasswordMode selects how userpass users get their password, empty is
+	// PasswordDefault. Generated passwords are added to Credentials.
+	PasswordMode PasswordMode
+	Generator    PasswordGenerator
+	Credentials  *CredentialsReport
+
+	// Concurrency is the number of secrets written in parallel
+	Concurrency int
+
+	// NamespaceMap renames namespaces from the backup. A key matches that
+	// namespace and everything below it, e.g. "team-a" => "apps/team-a".
+	NamespaceMap map[string]string
+
+	// Rewrite moves engines and secrets to new paths, nil keeps them
+	Rewrite *RewriteRules
+
+	// DatabaseOverrides supplies passwords and other connection fields of
+	// database mounts
+	DatabaseOverrides DatabaseOverrides
+
+	// AuthOverrides supplies write-only auth config fields such as bindpass
+	AuthOverrides AuthOverrides
+
+	// AppRoleSecretIDs supplies secret IDs to re-issue, keyed by accessor
+	AppRoleSecretIDs AppRoleSecretIDs
+
+	// PKIKeys supplies the private keys of pki issuers, which Vault does not
+	// export
+	PKIKeys PKIKeys
+
+	// Journal, when set, records completed work and skips work recorded
+	// by a previous run
+	Journal *Journal
 }
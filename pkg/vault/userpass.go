@@ -0,0 +1,254 @@
+package vault
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+)
+
+// PasswordMode selects the password restored userpass users get
+type PasswordMode string
+
+const (
+	// PasswordDefault gives every user RestoreOptions.DefaultPassword
+	PasswordDefault PasswordMode = "default"
+
+	// PasswordRandom gives every user a generated password, which is added
+	// to the credentials report
+	PasswordRandom PasswordMode = "random"
+
+	// PasswordHash copies the bcrypt hash from the backup through sys/raw, so
+	// users keep their password. Users without a hash get a random one.
+	PasswordHash PasswordMode = "hash"
+)
+
+const (
+	DefaultPasswordLength  = 24
+	DefaultPasswordCharset = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789-_.!"
+)
+
+// PasswordGenerator creates the passwords of PasswordRandom. With Policy set
+// they are generated by that password policy of the target Vault, otherwise
+// Length characters are picked from Charset.
+type PasswordGenerator struct {
+	Policy  string
+	Charset string
+	Length  int
+}
+
+func ParsePasswordMode(mode string) (PasswordMode, error) {
+	switch PasswordMode(mode) {
+	case PasswordDefault, PasswordRandom, PasswordHash:
+		return PasswordMode(mode), nil
+	}
+	return "", fmt.Errorf("invalid password mode %q: must be default, random or hash", mode)
+}
+
+// Credential is a password set on a restored user
+type Credential struct {
+	Mount    string `json:"mount"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CredentialsReport collects the generated passwords of a restore. Users are
+// restored by auth handlers, so adding is safe for concurrent use.
+type CredentialsReport struct {
+	mu          sync.Mutex
+	credentials []Credential
+}
+
+func (r *CredentialsReport) Add(mount, username, password string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.credentials = append(r.credentials, Credential{
+		Mount:    strings.TrimSuffix(mount, "/"),
+		Username: username,
+		Password: password,
+	})
+}
+
+func (r *CredentialsReport) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.credentials)
+}
+
+// WriteFile writes the report as JSON. An existing file is never replaced,
+// since it may hold the passwords of an earlier run.
+func (r *CredentialsReport) WriteFile(path string, encryption EncryptionOptions) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write credentials report: %w", err)
+	}
+	defer f.Close()
+
+	w, err := NewEncryptingWriter(f, encryption)
+	if err != nil {
+		return fmt.Errorf("failed to set up encryption: %w", err)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r.credentials); err != nil {
+		return fmt.Errorf("failed to write credentials report: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finalize credentials report: %w", err)
+	}
+	return f.Close()
+}
+
+func (c *Client) generatePassword(g PasswordGenerator) (string, error) {
+	if g.Policy != "" {
+		resp, err := c.client.Logical().Read("sys/policies/password/" + g.Policy + "/generate")
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password with policy %s: %w", g.Policy, err)
+		}
+		if resp == nil || stringValue(resp.Data["password"]) == "" {
+			return "", fmt.Errorf("password policy %s returned no password", g.Policy)
+		}
+		return stringValue(resp.Data["password"]), nil
+	}
+
+	charset := []rune(g.Charset)
+	if len(charset) == 0 {
+		charset = []rune(DefaultPasswordCharset)
+	}
+	length := g.Length
+	if length <= 0 {
+		length = DefaultPasswordLength
+	}
+
+	max := big.NewInt(int64(len(charset)))
+	password := make([]rune, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		password[i] = charset[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// CheckPasswordGenerator fails early when the password policy does not exist
+func (c *Client) CheckPasswordGenerator(g PasswordGenerator) error {
+	if g.Policy == "" {
+		return nil
+	}
+	resp, err := c.client.Logical().Read("sys/policies/password/" + g.Policy)
+	if err != nil {
+		return fmt.Errorf("failed to read password policy %s: %w", g.Policy, err)
+	}
+	if resp == nil {
+		return fmt.Errorf("password policy %s not found", g.Policy)
+	}
+	return nil
+}
+
+// checkRawStorage fails in a child namespace, where mounts are not stored
+// below auth/<uuid>/ of sys/raw
+func (c *Client) checkRawStorage() error {
+	if c.client.Namespace() != "" {
+		return fmt.Errorf("sys/raw is only available in the root namespace")
+	}
+	return nil
+}
+
+// userpassRawPath is where a userpass mount stores a user
+func (c *Client) userpassRawPath(mountUUID, username string) (string, error) {
+	if err := c.checkRawStorage(); err != nil {
+		return "", err
+	}
+	return "sys/raw/auth/" + mountUUID + "/user/" + strings.ToLower(username), nil
+}
+
+func (c *Client) readUserpassRecord(mountUUID, username string) (map[string]json.RawMessage, string, error) {
+	path, err := c.userpassRawPath(mountUUID, username)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := c.client.Logical().Read(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, "", fmt.Errorf("%s not found", path)
+	}
+
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(stringValue(resp.Data["value"])), &record); err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return record, path, nil
+}
+
+// backupUserpassHashes adds the bcrypt hash of every user, read from raw
+// storage of the mount
+func (c *Client) backupUserpassHashes(mountUUID string, auth *AuthMethodBackup) error {
+	copied := 0
+	for i, user := range auth.Users {
+		record, _, err := c.readUserpassRecord(mountUUID, user.Name)
+		if err != nil {
+			return err
+		}
+
+		var hash string
+		if err := json.Unmarshal(record["PasswordHash"], &hash); err != nil || hash == "" {
+			fmt.Printf("    Warning: user %s has no password hash\n", user.Name)
+			continue
+		}
+		auth.Users[i].PasswordHash = hash
+		copied++
+	}
+
+	fmt.Printf("    Backed up %d password hashes\n", copied)
+	return nil
+}
+
+// restoreUserpassHash replaces the hash of a restored user with the one from
+// the backup, keeping the rest of the record the target wrote
+func (c *Client) restoreUserpassHash(mountUUID, username, hash string) error {
+	record, path, err := c.readUserpassRecord(mountUUID, username)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(hash)
+	if err != nil {
+		return err
+	}
+	record["PasswordHash"] = encoded
+
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := c.client.Logical().Write(path, map[string]interface{}{"value": string(value)}); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// authMountUUID returns the UUID of the auth mount at path, which names its
+// storage
+func (c *Client) authMountUUID(path string) (string, error) {
+	auths, err := c.client.Sys().ListAuth()
+	if err != nil {
+		return "", fmt.Errorf("failed to list auth methods: %w", err)
+	}
+	auth, ok := auths[strings.TrimSuffix(path, "/")+"/"]
+	if !ok {
+		return "", fmt.Errorf("auth method %s not found", path)
+	}
+	return auth.UUID, nil
+}
@@ -0,0 +1,88 @@
+package vault
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParsePasswordMode(t *testing.T) {
+	for _, mode := range []string{"default", "random", "hash"} {
+		if got, err := ParsePasswordMode(mode); err != nil || string(got) != mode {
+			t.Errorf("ParsePasswordMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+	for _, mode := range []string{"", "Random", "bcrypt"} {
+		if _, err := ParsePasswordMode(mode); err == nil {
+			t.Errorf("ParsePasswordMode(%q): expected an error", mode)
+		}
+	}
+}
+
+func TestGeneratePasswordLocal(t *testing.T) {
+	tests := []struct {
+		generator PasswordGenerator
+		charset   string
+		length    int
+	}{
+		{PasswordGenerator{}, DefaultPasswordCharset, DefaultPasswordLength},
+		{PasswordGenerator{Charset: "ab", Length: 64}, "ab", 64},
+		{PasswordGenerator{Charset: "äöü", Length: 8}, "äöü", 8},
+		{PasswordGenerator{Length: -1}, DefaultPasswordCharset, DefaultPasswordLength},
+	}
+
+	c := &Client{}
+	for _, tt := range tests {
+		password, err := c.generatePassword(tt.generator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len([]rune(password)); n != tt.length {
+			t.Errorf("%+v: password has %d characters, want %d", tt.generator, n, tt.length)
+		}
+		for _, r := range password {
+			if !strings.ContainsRune(tt.charset, r) {
+				t.Errorf("%+v: password %q has %q outside the charset", tt.generator, password, r)
+				break
+			}
+		}
+	}
+
+	first, _ := c.generatePassword(PasswordGenerator{})
+	second, _ := c.generatePassword(PasswordGenerator{})
+	if first == second {
+		t.Error("two generated passwords are the same")
+	}
+}
+
+func TestGeneratePasswordPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/sys/policies/password/strong/generate":
+			fmt.Fprint(w, `{"data": {"password": "from-policy"}}`)
+		case "/v1/sys/policies/password/empty/generate":
+			fmt.Fprint(w, `{"data": {}}`)
+		default:
+			http.Error(w, `{"errors": []}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	opts := DefaultRequestOptions()
+	opts.MaxRetries = 0
+	c, err := NewClientWithOptions(server.URL, "test-token", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if password, err := c.generatePassword(PasswordGenerator{Policy: "strong", Length: 8}); err != nil || password != "from-policy" {
+		t.Errorf("generatePassword with policy = %q, %v", password, err)
+	}
+	for _, policy := range []string{"empty", "missing"} {
+		if _, err := c.generatePassword(PasswordGenerator{Policy: policy}); err == nil {
+			t.Errorf("policy %s: expected an error", policy)
+		}
+	}
+}